@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// Get the certifications of every supported country, from the mildest to the strictest
+func (app *application) getCertifications(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, models.CertificationSystems, "certifications")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Replace the certifications of a movie, they are keyed by country and an empty object clears them
+func (app *application) setMovieCertifications(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		MovieID        int               `json:"movie_id"`
+		Certifications map[string]string `json:"certifications"`
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validator.Check(payload.MovieID > 0, "movie_id", "invalid movie_id!")
+
+	countries := make([]string, 0, len(payload.Certifications))
+	for country := range payload.Certifications {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+
+	for _, country := range countries {
+		code := payload.Certifications[country]
+		_, ok := models.CertificationAge(country, code)
+		validator.Check(ok, "certifications", fmt.Sprintf("%s is not a certification of %s", code, country))
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.DB.SetMovieCertifications(payload.MovieID, payload.Certifications)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.MovieID
+	resp.Message = "certifications are successfully saved!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// collection payload
+type collectionPayload struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Slug        string `json:"slug"` // generated from the title when empty
+	Description string `json:"description"`
+	CoverImage  string `json:"cover_image"`
+	PublishAt   string `json:"publish_at"` // RFC 3339, empty keeps the collection a draft
+}
+
+// collection movies payload
+type collectionMoviesPayload struct {
+	CollectionID int   `json:"collection_id"`
+	MovieIDs     []int `json:"movie_ids"`
+}
+
+// writeCollectionError maps the errors of the collection models to a response
+func (app *application) writeCollectionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrCollectionNotFound):
+		app.errorJSON(w, err, http.StatusNotFound)
+	case errors.Is(err, models.ErrCollectionSlugTaken):
+		app.errorJSON(w, err, http.StatusConflict)
+	default:
+		app.errorJSON(w, err)
+	}
+}
+
+// Add or update a collection
+func (app *application) addOrUpdateCollection(w http.ResponseWriter, r *http.Request) {
+	var payload collectionPayload
+
+	// get admin id from context
+	adminID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	payload.Title = strings.TrimSpace(payload.Title)
+	payload.Slug = strings.TrimSpace(payload.Slug)
+	payload.Description = strings.TrimSpace(payload.Description)
+	payload.CoverImage = strings.TrimSpace(payload.CoverImage)
+
+	validator := validator.New()
+	validator.IsLength(payload.Title, "title", 3, 255)
+	validator.IsLength(payload.Slug, "slug", 0, 100)
+	validator.IsLength(payload.Description, "description", 0, 5000)
+	validator.IsLength(payload.CoverImage, "cover_image", 0, 255)
+
+	var publishAt *time.Time
+	if payload.PublishAt != "" {
+		t, err := time.Parse(time.RFC3339, payload.PublishAt)
+		if err != nil {
+			validator.AddError("publish_at", "publish_at should be a RFC 3339 date, like 2023-06-01T09:00:00Z")
+		} else {
+			// keep the clock of the server like the other timestamps
+			t = t.Local()
+			publishAt = &t
+		}
+	}
+
+	if r.Method == http.MethodPut && payload.ID <= 0 {
+		validator.AddError("id", "invalid id!")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	collection := models.Collection{
+		ID:          payload.ID,
+		Title:       payload.Title,
+		Slug:        payload.Slug,
+		Description: payload.Description,
+		PublishAt:   publishAt,
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Slug    string `json:"slug"`
+		Message string `json:"message"`
+	}
+
+	status := http.StatusCreated
+	if payload.ID > 0 {
+		err = app.models.DB.UpdateCollection(&collection, payload.CoverImage)
+		resp.ID = payload.ID
+		resp.Message = "Collection is updated successfully!"
+		status = http.StatusOK
+	} else {
+		resp.ID, err = app.models.DB.InsertCollection(&collection, payload.CoverImage, adminID)
+		resp.Message = "Collection is added successfully!"
+	}
+
+	if err != nil {
+		app.writeCollectionError(w, err)
+		return
+	}
+
+	resp.OK = true
+	resp.Slug = collection.Slug
+
+	err = app.writeJSON(w, status, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Delete a collection
+func (app *application) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil || id <= 0 {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	err = app.models.DB.DeleteCollection(id)
+	if err != nil {
+		app.writeCollectionError(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "collection is successfully deleted!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Replace the movies of a collection, in the given order
+func (app *application) setCollectionMovies(w http.ResponseWriter, r *http.Request) {
+	var payload collectionMoviesPayload
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	if payload.CollectionID <= 0 {
+		app.errorJSON(w, errors.New("invalid collection id"))
+		return
+	}
+
+	err = app.models.DB.SetCollectionMovies(payload.CollectionID, payload.MovieIDs)
+	if err != nil {
+		app.writeCollectionError(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.CollectionID
+	resp.Message = "movies of the collection are updated!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get a page of every collection with the drafts and the scheduled ones
+func (app *application) getAllCollections(w http.ResponseWriter, r *http.Request) {
+	app.listCollections(w, r, true)
+}
+
+// Get a page of the published collections, the latest first
+func (app *application) getCollections(w http.ResponseWriter, r *http.Request) {
+	app.listCollections(w, r, false)
+}
+
+// listCollections writes a page of the collections with their first movies
+func (app *application) listCollections(w http.ResponseWriter, r *http.Request, drafts bool) {
+	page, perPage, err := app.readPagination(r, defaultCollectionsPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// the admins see every movie of the collections
+	var cert *models.CertificationLimit
+	if !drafts {
+		userID, _ := app.parseHeaderToken(r)
+		var ok bool
+		cert, ok = app.certificationLimit(w, userID)
+		if !ok {
+			return
+		}
+	}
+
+	collections, err := app.models.DB.GetCollections(page, perPage, collectionPreviewMovies, drafts, cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the collections"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, collections)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get a published collection with all of its movies
+func (app *application) getCollection(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	userID, _ := app.parseHeaderToken(r)
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	collection, err := app.models.DB.GetCollectionBySlug(params.ByName("slug"), false, cert)
+	if err != nil {
+		if !errors.Is(err, models.ErrCollectionNotFound) {
+			app.logger.Println(err)
+			err = errors.New("failed to fetch the collection")
+		}
+		app.writeCollectionError(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, collection, "collection")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the home page, the featured movies, the movies trending today and the latest published collections
+func (app *application) getHome(w http.ResponseWriter, r *http.Request) {
+	var home struct {
+		Featured    []*models.Movie      `json:"featured"`
+		Trending    []*models.Movie      `json:"trending"`
+		Collections []*models.Collection `json:"collections"`
+	}
+
+	// the home page only changes with the certification limit of the user so it can be cached
+	userID, _ := app.parseHeaderToken(r)
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	featured, err := app.models.DB.GetFeatureMovies(cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the home page"), http.StatusInternalServerError)
+		return
+	}
+
+	trending, err := app.models.DB.GetTrendingMovies(models.TrendingWindows["24h"], homeTrendingMovies, cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the home page"), http.StatusInternalServerError)
+		return
+	}
+
+	collections, err := app.models.DB.GetCollections(1, homeCollections, collectionPreviewMovies, false, cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the home page"), http.StatusInternalServerError)
+		return
+	}
+
+	home.Featured = featured
+	home.Trending = trending
+	home.Collections = collections.Collections
+
+	err = app.writeJSON(w, http.StatusOK, home)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
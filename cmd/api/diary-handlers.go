@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// diary entry payload
+type diaryPayload struct {
+	ID        int      `json:"id"`
+	MovieID   int      `json:"movie_id"`
+	WatchedOn string   `json:"watched_on"` // YYYY-MM-DD, today on a new entry when empty
+	Rating    *float64 `json:"rating"`     // an update keeps the rating when empty
+	IsRewatch *bool    `json:"is_rewatch"` // worked out from the earlier entries when empty
+}
+
+// Add or Update an entry of the diary of the logged in user, a payload with an id updates the entry
+func (app *application) addOrUpdateDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	var payload diaryPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+
+	// a new entry is watched today unless told otherwise, an update keeps the date it has
+	var watchedOn time.Time
+	if r.Method != http.MethodPut {
+		watchedOn = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if payload.WatchedOn != "" {
+		watchedOn, err = time.Parse(models.DiaryDateLayout, payload.WatchedOn)
+		if err != nil {
+			validator.AddError("watched_on", "watched_on should be a date like 2006-01-02")
+		}
+	}
+
+	// a day of margin for users ahead of UTC
+	validator.Check(watchedOn.Before(time.Now().AddDate(0, 0, 1)), "watched_on", "watched_on can't be in the future")
+
+	if payload.Rating != nil && (*payload.Rating < 1.0 || *payload.Rating > 10.0) {
+		validator.AddError("rating", "movie rating should be between 1.0 to 10.0")
+	}
+
+	if r.Method == http.MethodPut {
+		validator.Check(payload.ID > 0, "id", "invalid id!")
+	} else {
+		validator.Check(payload.MovieID > 0, "movie_id", "invalid movie_id!")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	entry := models.DiaryEntry{
+		ID:        payload.ID,
+		UserID:    userID,
+		MovieID:   payload.MovieID,
+		WatchedOn: watchedOn,
+	}
+
+	if payload.Rating != nil {
+		rating := float32(*payload.Rating)
+		entry.Rating = &rating
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	status := http.StatusCreated
+	if payload.ID > 0 {
+		err = app.models.DB.UpdateDiaryEntry(&entry, payload.IsRewatch)
+		resp.ID = payload.ID
+		resp.Message = "Diary entry is updated successfully!"
+		status = http.StatusOK
+	} else {
+		if payload.IsRewatch != nil {
+			entry.IsRewatch = *payload.IsRewatch
+		} else {
+			entry.IsRewatch, err = app.models.DB.HasWatched(userID, payload.MovieID)
+			if err != nil {
+				app.logger.Println(err)
+				app.errorJSON(w, errors.New("failed to add the diary entry"), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		resp.ID, err = app.models.DB.InsertDiaryEntry(&entry)
+		resp.Message = "Diary entry is added successfully!"
+	}
+
+	if errors.Is(err, models.ErrDiaryEntryNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	resp.OK = true
+
+	err = app.writeJSON(w, status, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Delete an entry of the diary of the logged in user
+func (app *application) deleteDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	err = app.models.DB.DeleteDiaryEntry(id, userID)
+	if errors.Is(err, models.ErrDiaryEntryNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "diary entry is successfully deleted!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the diary of the logged in user. With a year and a month the viewings of that month are grouped by day,
+// with only a year the viewings are counted month by month, otherwise the entries are paginated
+func (app *application) getDiary(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	queryValues := r.URL.Query()
+
+	if queryValues.Get("year") == "" {
+		if queryValues.Get("month") != "" {
+			app.errorJSON(w, errors.New("month needs a year"))
+			return
+		}
+
+		page, perPage, err := app.readPagination(r, defaultDiaryPerPage)
+		if err != nil {
+			app.errorJSON(w, err)
+			return
+		}
+
+		diary, err := app.models.DB.GetDiary(userID, page, perPage)
+		if err != nil {
+			app.logger.Println(err)
+			app.errorJSON(w, errors.New("failed to fetch the diary"), http.StatusInternalServerError)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, diary)
+		if err != nil {
+			app.errorJSON(w, err)
+		}
+		return
+	}
+
+	year, err := strconv.Atoi(queryValues.Get("year"))
+	if err != nil || year < 1888 || year > 9999 {
+		app.errorJSON(w, errors.New("year should be a valid year"))
+		return
+	}
+
+	if queryValues.Get("month") == "" {
+		summary, err := app.models.DB.GetDiaryYear(userID, year)
+		if err != nil {
+			app.logger.Println(err)
+			app.errorJSON(w, errors.New("failed to fetch the diary"), http.StatusInternalServerError)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, summary, "diary")
+		if err != nil {
+			app.errorJSON(w, err)
+		}
+		return
+	}
+
+	month, err := strconv.Atoi(queryValues.Get("month"))
+	if err != nil || month < 1 || month > 12 {
+		app.errorJSON(w, errors.New("month should be a number between 1 and 12"))
+		return
+	}
+
+	calendar, err := app.models.DB.GetDiaryMonth(userID, year, month)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the diary"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, calendar, "diary")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+)
+
+// Get the favorite movies of the logged in user, sorted by the date they were added or by title
+func (app *application) getMyFavorites(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultFavoritesPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	filter := models.MovieFilter{FavoritesOf: userID}
+
+	// set up sort option
+	switch r.URL.Query().Get("sort") {
+	case "", "added":
+		filter.OrderBy = models.MovieOrderFavorited
+	case "title":
+		filter.OrderBy = "name"
+	default:
+		app.errorJSON(w, errors.New("sort should be one of added or title"))
+		return
+	}
+
+	filter.Certification, ok = app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	movies, err := app.models.DB.GetAllMoviesByFilter(page, perPage, &filter, userID)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the favorites"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, movies)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Add a movie to the favorites of the logged in user, adding it again changes nothing
+func (app *application) putFavorite(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	movieID, err := strconv.Atoi(ps.ByName("movie_id"))
+	if err != nil || movieID <= 0 {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// check if the movie exists
+	_, err = app.models.DB.Get(movieID)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid movie id"), http.StatusNotFound)
+		return
+	}
+
+	favorite := models.Favorite{
+		UserID:    userID,
+		MovieID:   movieID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	_, err = app.models.DB.AddFavorite(&favorite)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = movieID
+	resp.Message = "movie is in your favorites!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Remove a movie from the favorites of the logged in user, removing a movie that is not a favorite changes nothing
+func (app *application) deleteFavorite(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	movieID, err := strconv.Atoi(ps.ByName("movie_id"))
+	if err != nil || movieID <= 0 {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	err = app.models.DB.RemoveUserFavorite(userID, movieID)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = movieID
+	resp.Message = "movie is not in your favorites!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+)
+
+// follow request payload
+type followRequestPayload struct {
+	UserID int  `json:"user_id"` // the follower who sent the request
+	Accept bool `json:"accept"`
+}
+
+// Follow a user, the follow of a private profile waits for its approval
+func (app *application) followUser(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	followeeID, err := strconv.Atoi(ps.ByName("user_id"))
+	if err != nil || followeeID <= 0 {
+		app.errorJSON(w, errors.New("invalid user id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	status, err := app.models.DB.FollowUser(userID, followeeID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = followeeID
+	resp.Status = status
+	resp.Message = "you are following the user!"
+	if status == models.FollowPending {
+		resp.Message = "follow request is sent, it waits for the approval of the user!"
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Stop following a user or cancel a follow request
+func (app *application) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	followeeID, err := strconv.Atoi(ps.ByName("user_id"))
+	if err != nil || followeeID <= 0 {
+		app.errorJSON(w, errors.New("invalid user id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	err = app.models.DB.UnfollowUser(userID, followeeID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = followeeID
+	resp.Message = "you are not following the user!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Accept or decline a follow request to the logged in user
+func (app *application) respondFollowRequest(w http.ResponseWriter, r *http.Request) {
+	var payload followRequestPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	if payload.UserID <= 0 {
+		app.errorJSON(w, errors.New("invalid user id"))
+		return
+	}
+
+	err = app.models.DB.RespondFollowRequest(userID, payload.UserID, payload.Accept)
+	if errors.Is(err, models.ErrFollowRequestNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.UserID
+	resp.Message = "follow request is declined!"
+	if payload.Accept {
+		resp.Message = "follow request is accepted!"
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// listFollows writes a page of the followers of the logged in user, or of the users they follow
+func (app *application) listFollows(w http.ResponseWriter, r *http.Request, following bool, status string) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultFollowsPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	follows, err := app.models.DB.GetFollows(userID, following, status, page, perPage)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the users"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, follows)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the followers of the logged in user
+func (app *application) getFollowers(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, false, models.FollowAccepted)
+}
+
+// Get the users followed by the logged in user, the requests waiting for approval are listed with status=pending
+func (app *application) getFollowing(w http.ResponseWriter, r *http.Request) {
+	status := models.FollowAccepted
+	if r.URL.Query().Get("status") == models.FollowPending {
+		status = models.FollowPending
+	}
+
+	app.listFollows(w, r, true, status)
+}
+
+// Get the follow requests waiting for the approval of the logged in user
+func (app *application) getFollowRequests(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, false, models.FollowPending)
+}
+
+// Get the activity feed of the logged in user, the ratings, reviews, comments and list updates of the users
+// they follow
+func (app *application) getFeed(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultFeedPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	feed, err := app.models.DB.GetFeed(userID, page, perPage)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the feed"), http.StatusInternalServerError)
+		return
+	}
+
+	reveal := app.revealSpoilers(r, userID)
+	for _, activity := range feed.Activities {
+		activity.RenderSpoilers(reveal)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, feed)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
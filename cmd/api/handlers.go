@@ -15,6 +15,7 @@ import (
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"github.com/golang-jwt/jwt"
 	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/contentfilter"
 	"github.com/raihan2bd/filmwise/models"
 	"github.com/raihan2bd/filmwise/validator"
 	"golang.org/x/crypto/bcrypt"
@@ -24,6 +25,36 @@ import (
 const (
 	defaultPage    = 1
 	defaultPerPage = 3
+
+	defaultCommentsPerPage = 10
+	maxCommentsPerPage     = 50
+
+	defaultReviewsPerPage   = 10
+	defaultRatingsPerPage   = 20
+	defaultListsPerPage     = 20
+	defaultWatchlistPerPage = 20
+	defaultDiaryPerPage     = 30
+	defaultFavoritesPerPage = 20
+	defaultFollowsPerPage   = 20
+	defaultFeedPerPage      = 20
+	defaultRelatedMovies    = 10
+	defaultRecommendations  = 20
+	defaultTrendingMovies   = 20
+	maxPerPage              = 50
+
+	defaultCollectionsPerPage = 10
+	collectionPreviewMovies   = 10 // movies shown with each collection of a page
+	homeCollections           = 5
+	homeTrendingMovies        = 10
+
+	defaultTagSuggestionsPerPage = 20
+	defaultPopularTags           = 30
+	defaultRelatedTags           = 10
+
+	defaultStudios = 30
+
+	// how long clients and proxies may cache the public responses marked cacheable
+	publicCacheMaxAge = 5 * time.Minute
 )
 
 type MoviePayload struct {
@@ -35,6 +66,16 @@ type MoviePayload struct {
 	Runtime     string         `json:"runtime"`
 	ImageID     string         `json:"image_id"`
 	MovieGenre  map[int]string `json:"genres"`
+
+	OriginalTitle string   `json:"original_title"`
+	Tagline       string   `json:"tagline"`
+	Budget        string   `json:"budget"`
+	Revenue       string   `json:"revenue"`
+	IMDbID        string   `json:"imdb_id"`
+	TMDbID        string   `json:"tmdb_id"`
+	Languages     []string `json:"languages"` // ISO 639-1 codes, like en
+	Countries     []string `json:"countries"` // ISO 3166-1 codes, like US
+	Studios       []string `json:"studios"`   // the names of the studios, new ones are created
 }
 
 func (app *application) GetStatus(w http.ResponseWriter, r *http.Request) {
@@ -51,8 +92,16 @@ func (app *application) GetStatus(w http.ResponseWriter, r *http.Request) {
 
 // Get all movies
 func (app *application) getAllMovies(w http.ResponseWriter, r *http.Request) {
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
 	// get all movies from the database
-	movies, err := app.models.DB.GetAllMovies("the")
+	movies, err := app.models.DB.GetAllMovies("the", cert)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -67,7 +116,15 @@ func (app *application) getAllMovies(w http.ResponseWriter, r *http.Request) {
 
 // Get feature movies
 func (app *application) getFeatureMovies(w http.ResponseWriter, r *http.Request) {
-	movies, err := app.models.DB.GetFeatureMovies()
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	movies, err := app.models.DB.GetFeatureMovies(cert, userID)
 
 	if err != nil {
 		app.errorJSON(w, err)
@@ -114,9 +171,41 @@ func (app *application) getAllMoviesByFilter(w http.ResponseWriter, r *http.Requ
 		perPage = pp
 	}
 
-	gID, err := strconv.Atoi(queryValues.Get("genre"))
-	if err == nil {
-		filter.FilterByGenre = gID
+	// the genre is given by its id or its slug
+	if genre := queryValues.Get("genre"); genre != "" {
+		genreID, err := app.genreID(genre)
+		if err != nil {
+			app.errorJSON(w, err, http.StatusNotFound)
+			return
+		}
+		filter.FilterByGenre = genreID
+	}
+
+	if tag := queryValues.Get("tag"); tag != "" {
+		t, err := app.models.DB.GetTagBySlug(tag, 0)
+		if err != nil {
+			app.errorJSON(w, models.ErrTagNotFound, http.StatusNotFound)
+			return
+		}
+		filter.FilterByTag = t.ID
+	}
+
+	if studio := queryValues.Get("studio"); studio != "" {
+		s, err := app.models.DB.GetStudioBySlug(studio)
+		if err != nil {
+			app.errorJSON(w, models.ErrStudioNotFound, http.StatusNotFound)
+			return
+		}
+		filter.FilterByStudio = s.ID
+	}
+
+	// unknown codes match no movie
+	if language := queryValues.Get("language"); language != "" {
+		filter.FilterByLanguage, _ = models.LanguageCode(language)
+	}
+
+	if country := queryValues.Get("country"); country != "" {
+		filter.FilterByCountry, _ = models.CountryCode(country)
 	}
 
 	if queryValues.Get("year") != "" {
@@ -130,6 +219,11 @@ func (app *application) getAllMoviesByFilter(w http.ResponseWriter, r *http.Requ
 
 	// get userID from bareaer token
 	userID, _ := app.parseHeaderToken(r)
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+	filter.Certification = cert
 
 	movies, err := app.models.DB.GetAllMoviesByFilter(page, perPage, &filter, userID)
 	if err != nil {
@@ -144,25 +238,80 @@ func (app *application) getAllMoviesByFilter(w http.ResponseWriter, r *http.Requ
 	}
 }
 
-// Get all movies by genre
+// Get the movies of a genre and of its sub genres, the genre is given by its slug or its id
 func (app *application) getAllMoviesByGenre(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 
-	genreID, err := strconv.Atoi(params.ByName("genre_id"))
+	genreID, err := app.genreID(params.ByName("genre"))
+	if err != nil {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	filter := models.MovieFilter{
+		FilterByGenre: genreID,
+		OrderBy:       r.URL.Query().Get("order_by"),
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+	filter.Certification = cert
+
+	movies, err := app.models.DB.GetAllMoviesByFilter(page, perPage, &filter, userID)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
 
-	movies, err := app.models.DB.GetAllMovies("")
+	err = app.writeJSON(w, http.StatusOK, movies)
 	if err != nil {
 		app.errorJSON(w, err)
 		return
 	}
+}
 
-	app.logger.Println(genreID)
+// genreID returns the id of the genre with the given slug, or id when it is a number
+func (app *application) genreID(genre string) (int, error) {
+	if id, err := strconv.Atoi(genre); err == nil {
+		if _, err := app.models.DB.CheckGenre(id); err != nil {
+			return 0, models.ErrGenreNotFound
+		}
+		return id, nil
+	}
 
-	err = app.writeJSON(w, http.StatusOK, movies, "movies")
+	g, err := app.models.DB.GenreBySlug(genre)
+	if err != nil {
+		return 0, models.ErrGenreNotFound
+	}
+
+	return g.ID, nil
+}
+
+// Get a genre with its sub genres
+func (app *application) getGenre(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	genre, err := app.models.DB.GenreBySlug(params.ByName("slug"))
+	if errors.Is(err, models.ErrGenreNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, genre, "genre")
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -216,14 +365,57 @@ func (app *application) AddNewMovie(w http.ResponseWriter, r *http.Request) {
 		validator.AddError("runtime", "invalid runtime!")
 	}
 
-	if len(payload.MovieGenre) <= 0 {
-		validator.AddError("genres", "movie genre is required")
-	}
-
 	if len(payload.MovieGenre) > 5 {
 		validator.AddError("genres", "maximum 5 genres are allowed")
 	}
 
+	validator.IsLength(payload.OriginalTitle, "original_title", 0, 255)
+	validator.IsLength(payload.Tagline, "tagline", 0, 255)
+
+	var budget, revenue int64
+	if payload.Budget != "" {
+		budget, err = strconv.ParseInt(payload.Budget, 10, 64)
+		validator.Check(err == nil && budget >= 0, "budget", "invalid budget!")
+	}
+
+	if payload.Revenue != "" {
+		revenue, err = strconv.ParseInt(payload.Revenue, 10, 64)
+		validator.Check(err == nil && revenue >= 0, "revenue", "invalid revenue!")
+	}
+
+	imdbID := strings.TrimSpace(payload.IMDbID)
+	validator.Check(imdbID == "" || models.ValidIMDbID(imdbID), "imdb_id", "imdb_id should look like tt0111161")
+
+	var tmdbID int
+	if payload.TMDbID != "" {
+		tmdbID, err = strconv.Atoi(payload.TMDbID)
+		validator.Check(err == nil && tmdbID > 0, "tmdb_id", "invalid tmdb_id!")
+	}
+
+	var languages, countries []string
+	for _, l := range payload.Languages {
+		code, ok := models.LanguageCode(l)
+		validator.Check(ok, "languages", "languages should be ISO 639-1 codes, like en")
+		languages = append(languages, code)
+	}
+
+	for _, c := range payload.Countries {
+		code, ok := models.CountryCode(c)
+		validator.Check(ok, "countries", "countries should be ISO 3166-1 codes, like US")
+		countries = append(countries, code)
+	}
+
+	var studios []*models.Studio
+	for _, name := range payload.Studios {
+		validator.IsLength(models.TagName(name), "studios", 2, 100, "a studio name must be between 2 and 100 characters")
+		validator.Check(models.StudioSlug(name) != "", "studios", "a studio name should have letters or digits")
+		studios = append(studios, &models.Studio{Name: name})
+	}
+
+	validator.Check(len(languages) <= models.MovieMaxStudios, "languages", "too many languages")
+	validator.Check(len(countries) <= models.MovieMaxStudios, "countries", "too many countries")
+	validator.Check(len(studios) <= models.MovieMaxStudios, "studios", "too many studios")
+
 	if !validator.Valid() {
 		err := app.writeJSON(w, http.StatusBadRequest, validator)
 		if err != nil {
@@ -240,6 +432,15 @@ func (app *application) AddNewMovie(w http.ResponseWriter, r *http.Request) {
 	movie.ReleaseDate = releaseDate
 	movie.Runtime = runtime
 	movie.MovieGenre = payload.MovieGenre
+	movie.OriginalTitle = strings.TrimSpace(payload.OriginalTitle)
+	movie.Tagline = strings.TrimSpace(payload.Tagline)
+	movie.Budget = budget
+	movie.Revenue = revenue
+	movie.IMDbID = imdbID
+	movie.TMDbID = tmdbID
+	movie.Languages = languages
+	movie.Countries = countries
+	movie.Studios = studios
 
 	if len(payload.ID) > 0 {
 		editMovieID, err := strconv.Atoi(payload.ID)
@@ -315,7 +516,11 @@ func (app *application) AddNewMovie(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		app.errorJSON(w, err)
+		status := http.StatusBadRequest
+		if errors.Is(err, models.ErrIMDbIDTaken) || errors.Is(err, models.ErrTMDbIDTaken) {
+			status = http.StatusConflict
+		}
+		app.errorJSON(w, err, status)
 		return
 	}
 
@@ -354,6 +559,11 @@ func (app *application) getOneMovie(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	reveal := app.revealSpoilers(r, userID)
+	for i := range movie.Comments {
+		movie.Comments[i].RenderSpoilers(reveal)
+	}
+
 	err = app.writeJSON(w, http.StatusOK, movie, "movie")
 	if err != nil {
 		app.errorJSON(w, err)
@@ -417,11 +627,15 @@ func (app *application) deleteMovie(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// Add or Update genre
+// Add or Update genre, an update keeps the stored value of every field left out
 func (app *application) addOrUpdateGenre(w http.ResponseWriter, r *http.Request) {
 	var payload struct {
-		ID        string `json:"genre_id"`
-		GenreName string `json:"genre_name"`
+		ID          string  `json:"genre_id"`
+		GenreName   *string `json:"genre_name"`
+		Slug        *string `json:"slug"` // generated from the name when empty
+		Description *string `json:"description"`
+		ParentID    *int    `json:"parent_id"` // zero for a top level genre
+		IsDefault   *bool   `json:"is_default"`
 	}
 
 	// read json from the body
@@ -432,6 +646,7 @@ func (app *application) addOrUpdateGenre(w http.ResponseWriter, r *http.Request)
 	}
 
 	id := 0
+	var genre models.Genre
 
 	if payload.ID != "" {
 		id, err = strconv.Atoi(payload.ID)
@@ -439,16 +654,38 @@ func (app *application) addOrUpdateGenre(w http.ResponseWriter, r *http.Request)
 			app.badRequest(w, r, errors.New("invalid genre id"))
 			return
 		}
-		_, err = app.models.DB.CheckGenre(id)
+		genre, err = app.models.DB.GenreByID(id)
 		if err != nil {
 			app.badRequest(w, r, errors.New("invalid genre id"))
 			return
 		}
 	}
 
+	if payload.GenreName != nil {
+		genre.GenreName = strings.TrimSpace(*payload.GenreName)
+	}
+	if payload.Slug != nil {
+		genre.Slug = strings.TrimSpace(*payload.Slug)
+	}
+	if payload.Description != nil {
+		genre.Description = strings.TrimSpace(*payload.Description)
+	}
+	if payload.ParentID != nil {
+		genre.ParentID = nil
+		if *payload.ParentID > 0 {
+			genre.ParentID = payload.ParentID
+		}
+	}
+	if payload.IsDefault != nil {
+		genre.IsDefault = *payload.IsDefault
+	}
+
 	// validate genre name
 	validator := validator.New()
-	validator.IsLength(payload.GenreName, "genre_name", 3, 50)
+	validator.IsLength(genre.GenreName, "genre_name", 3, 50)
+	validator.IsLength(genre.Slug, "slug", 0, 100)
+	validator.IsLength(genre.Description, "description", 0, 2000)
+	validator.Check(payload.ParentID == nil || *payload.ParentID >= 0, "parent_id", "invalid parent_id!")
 
 	// if len(payload.GenreName) < 3 || len(payload.GenreName) > 50 {
 	// 	app.badRequest(w, r, errors.New("genre name must be between 3 and 50 characters"))
@@ -468,12 +705,20 @@ func (app *application) addOrUpdateGenre(w http.ResponseWriter, r *http.Request)
 	respMsg := "Genre is inserted successfully!"
 
 	if id > 0 {
-		genreID, err = app.models.DB.UpdateGenre(id, payload.GenreName)
+		genreID, err = app.models.DB.UpdateGenre(&genre)
 		respMsg = "Genre is successfully updated"
 	} else {
-		genreID, err = app.models.DB.InsertGenre(payload.GenreName)
+		genreID, err = app.models.DB.InsertGenre(&genre)
 	}
 
+	if errors.Is(err, models.ErrGenreNameTaken) || errors.Is(err, models.ErrGenreSlugTaken) {
+		app.errorJSON(w, err, http.StatusConflict)
+		return
+	}
+	if errors.Is(err, models.ErrGenreNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -507,6 +752,10 @@ func (app *application) deleteGenre(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = app.models.DB.DeleteGenre(id)
+	if errors.Is(err, models.ErrGenreNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -625,6 +874,7 @@ type commentPayload struct {
 	Comment   string `json:"comment"`
 	MovieID   int    `json:"movie_id"`
 	CommentID string `json:"comment_id"`
+	ParentID  int    `json:"parent_id"`
 }
 
 // Add or Update a comment
@@ -650,30 +900,42 @@ func (app *application) addOrUpdateComment(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	id := 0
+	var existing *models.Comment
 
 	if payload.CommentID != "" {
-		id, err = strconv.Atoi(payload.CommentID)
+		id, err := strconv.Atoi(payload.CommentID)
 		if err != nil {
 			app.badRequest(w, r, errors.New("invalid comment id"))
 			return
 		}
-		_, err = app.models.DB.CheckComment(id)
-		if err != nil {
+		existing, err = app.models.DB.GetComment(id)
+		if err != nil || existing.IsDeleted {
 			app.badRequest(w, r, errors.New("invalid comment id"))
 			return
 		}
+
+		// only the author can edit a comment
+		if existing.UserID != userID {
+			app.errorJSON(w, errors.New("you can only edit your own comments"), http.StatusForbidden)
+			return
+		}
+
+		// a comment hidden by moderators stays as it is until they decide
+		if existing.IsHidden {
+			app.errorJSON(w, errors.New("comment is hidden by moderators"), http.StatusForbidden)
+			return
+		}
 	}
 
 	validator := validator.New()
 	validator.IsLength(payload.Comment, "comment", 10, 500)
 
-	if payload.MovieID <= 0 {
+	if payload.MovieID <= 0 && existing == nil {
 		validator.AddError("movie_id", "invalid movie_id!")
 	}
 
-	if err != nil {
-		validator.AddError("movie_id", "invalid movie_id!")
+	if payload.ParentID < 0 {
+		validator.AddError("parent_id", "invalid parent_id!")
 	}
 
 	if !validator.Valid() {
@@ -685,13 +947,31 @@ func (app *application) addOrUpdateComment(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// check the text with the content filter, it may be masked
+	input := contentfilter.Input{Kind: contentfilter.KindComment, UserID: userID, Text: payload.Comment}
+	if existing != nil {
+		input.ID = existing.ID
+	}
+
+	filtered := app.filterText(w, input)
+	if filtered == nil {
+		return
+	}
+
 	var comment models.Comment
-	comment.Comment = strings.Trim(payload.Comment, "")
+	comment.Comment = strings.Trim(filtered.Text, "")
 	comment.MovieID = payload.MovieID
+	comment.ParentID = payload.ParentID
 	comment.UserID = userID
 	comment.CreatedAt = time.Now()
 	comment.UpdatedAt = time.Now()
 
+	if existing != nil {
+		comment.ID = existing.ID
+		comment.MovieID = existing.MovieID
+		comment.ParentID = existing.ParentID
+	}
+
 	var resp struct {
 		OK      bool   `json:"ok"`
 		ID      int    `json:"id"`
@@ -712,6 +992,10 @@ func (app *application) addOrUpdateComment(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if app.queueForReview(filtered, models.ReportTargetComment, commentID, userID) {
+		respMsg = "Comment is saved and waits for a review by the moderators!"
+	}
+
 	resp.OK = true
 	resp.ID = commentID
 	resp.Message = respMsg
@@ -724,23 +1008,35 @@ func (app *application) addOrUpdateComment(w http.ResponseWriter, r *http.Reques
 
 // Delete a comment
 func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
+	ps := r.Context().Value("params").(httprouter.Params)
 
-	id, err := strconv.Atoi(params.ByName("id"))
+	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
 		app.errorJSON(w, errors.New("invalid id"))
 		return
 	}
 
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
 	// check if the comment exists
-	_, err = app.models.DB.GetComment(id)
-	if err != nil {
+	comment, err := app.models.DB.GetComment(id)
+	if err != nil || comment.IsDeleted {
 		app.errorJSON(w, errors.New("invalid comment id"))
 		return
 	}
 
-	// user authentication will be added later
-	err = app.models.DB.DeleteComment(id)
+	// only the author can delete a comment here, moderators use the remove route
+	if comment.UserID != userID {
+		app.errorJSON(w, errors.New("you can only delete your own comments"), http.StatusForbidden)
+		return
+	}
+
+	err = app.models.DB.DeleteComment(id, userID, "")
 	if err != nil {
 		app.errorJSON(w, err)
 		return
@@ -752,7 +1048,7 @@ func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
 		Message string `json:"message"`
 	}
 
-	resp.OK = false
+	resp.OK = true
 	resp.ID = id
 	resp.Message = "comment is successfully deleted!"
 
@@ -763,6 +1059,335 @@ func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Remove any comment as a moderator, the reason is kept with the comment
+func (app *application) removeComment(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		CommentID int    `json:"comment_id"`
+		Reason    string `json:"reason"`
+	}
+
+	// get moderator id from context
+	moderatorID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validator.IsLength(payload.Reason, "reason", 3, 255)
+
+	if payload.CommentID <= 0 {
+		validator.AddError("comment_id", "invalid comment_id!")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	comment, err := app.models.DB.GetComment(payload.CommentID)
+	if err != nil || comment.IsDeleted {
+		app.errorJSON(w, errors.New("invalid comment id"))
+		return
+	}
+
+	err = app.models.DB.DeleteComment(comment.ID, moderatorID, strings.TrimSpace(payload.Reason))
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = comment.ID
+	resp.Message = "comment is successfully removed!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Restore a deleted or removed comment
+func (app *application) restoreComment(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	comment, err := app.models.DB.GetComment(id)
+	if err != nil || !comment.IsDeleted {
+		app.errorJSON(w, errors.New("invalid comment id"))
+		return
+	}
+
+	err = app.models.DB.RestoreComment(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "comment is successfully restored!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Get the full edit history of a comment
+func (app *application) getCommentHistory(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	comment, err := app.models.DB.GetComment(id)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid comment id"), http.StatusNotFound)
+		return
+	}
+
+	revisions, err := app.models.DB.GetCommentRevisions(id)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the comment history"), http.StatusInternalServerError)
+		return
+	}
+
+	var resp struct {
+		Comment   *models.Comment           `json:"comment"`
+		Revisions []*models.CommentRevision `json:"revisions"`
+	}
+
+	resp.Comment = comment
+	resp.Revisions = revisions
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Get a page of top level comments of a movie
+func (app *application) getMovieComments(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	movieID, err := strconv.Atoi(params.ByName("movie_id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	queryValues := r.URL.Query()
+
+	filter := models.CommentFilter{
+		MovieID: movieID,
+		SortBy:  models.CommentSortNewest,
+		Cursor:  queryValues.Get("cursor"),
+		Limit:   defaultCommentsPerPage,
+	}
+
+	// set up sort option
+	switch queryValues.Get("sort") {
+	case "", models.CommentSortNewest:
+	case models.CommentSortOldest, models.CommentSortTop:
+		filter.SortBy = queryValues.Get("sort")
+	default:
+		app.errorJSON(w, errors.New("sort should be one of newest, oldest or top"))
+		return
+	}
+
+	// set up per page limit
+	if queryValues.Get("limit") != "" {
+		limit, err := strconv.Atoi(queryValues.Get("limit"))
+		if err != nil || limit <= 0 {
+			app.errorJSON(w, errors.New("per page limit should be a positive number"))
+			return
+		}
+		if limit > maxCommentsPerPage {
+			limit = maxCommentsPerPage
+		}
+		filter.Limit = limit
+	}
+
+	// check if the movie exists
+	_, err = app.models.DB.Get(movieID)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid movie id"), http.StatusNotFound)
+		return
+	}
+
+	// get userID from bareaer token
+	filter.UserID, _ = app.parseHeaderToken(r)
+
+	comments, err := app.models.DB.ListComments(&filter)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.errorJSON(w, err)
+			return
+		}
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the comments"), http.StatusInternalServerError)
+		return
+	}
+
+	reveal := app.revealSpoilers(r, filter.UserID)
+	for i := range comments.Comments {
+		comments.Comments[i].RenderSpoilers(reveal)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, comments)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Get a comment with all of its replies
+func (app *application) getCommentThread(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id parameter"))
+		return
+	}
+
+	userID, _ := app.parseHeaderToken(r)
+
+	thread, err := app.models.DB.GetCommentThread(id, userID)
+	if err != nil {
+		app.errorJSON(w, errors.New("failed to fetch the comment thread"), http.StatusNotFound)
+		return
+	}
+
+	thread.RenderSpoilers(app.revealSpoilers(r, userID))
+
+	err = app.writeJSON(w, http.StatusOK, thread, "comment")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// React to a comment, sending the same reaction again removes it
+func (app *application) toggleCommentReaction(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		CommentID int    `json:"comment_id"`
+		Reaction  string `json:"reaction"`
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	if userID <= 0 {
+		app.errorJSON(w, errors.New("authentication failed"), http.StatusUnauthorized)
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	if payload.CommentID <= 0 {
+		validator.AddError("comment_id", "invalid comment_id!")
+	}
+
+	if payload.Reaction != models.ReactionLike && payload.Reaction != models.ReactionHelpful {
+		validator.AddError("reaction", "reaction should be like or helpful")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	// check if the comment exists
+	comment, err := app.models.DB.GetComment(payload.CommentID)
+	if err != nil || comment.IsDeleted || comment.IsHidden {
+		app.errorJSON(w, errors.New("invalid comment id"))
+		return
+	}
+
+	if comment.UserID == userID {
+		app.errorJSON(w, errors.New("you can't react to your own comment"))
+		return
+	}
+
+	reaction, err := app.models.DB.ToggleCommentReaction(comment.ID, userID, payload.Reaction)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK       bool   `json:"ok"`
+		ID       int    `json:"id"`
+		Reaction string `json:"reaction"`
+		Message  string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = comment.ID
+	resp.Reaction = reaction
+	resp.Message = "reaction is successfully added!"
+	if reaction == "" {
+		resp.Message = "reaction is successfully removed!"
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
 // Add or Update Favorite
 func (app *application) addOrUpdateFavorite(w http.ResponseWriter, r *http.Request) {
 	ps := r.Context().Value("params").(httprouter.Params)
@@ -792,7 +1417,12 @@ func (app *application) addOrUpdateFavorite(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	favID, _ := app.models.DB.FindFavorites(userID, movieID)
+	favID, err := app.models.DB.FindFavorites(userID, movieID)
+	if err != nil && !errors.Is(err, models.ErrFavoriteNotFound) {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the favorite"), http.StatusInternalServerError)
+		return
+	}
 
 	respMsg := "movie is successfully added to favorites!"
 
@@ -1078,6 +1708,11 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// banned or suspended users can't sign in
+	if app.rejectSanctioned(w, user.ID) {
+		return
+	}
+
 	// custom claims
 	claims := CustomClaims{
 		UserType: user.UserType,
@@ -1156,6 +1791,13 @@ func (app *application) signUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// check the display name with the content filter, it may be masked
+	filtered := app.filterText(w, contentfilter.Input{Kind: contentfilter.KindDisplayName, Text: payload.FullName})
+	if filtered == nil {
+		return
+	}
+	payload.FullName = filtered.Text
+
 	// convert the password into hash
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), 12)
 
@@ -1171,6 +1813,13 @@ func (app *application) signUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// a queued display name is reported to the moderators
+	if filtered.Action == contentfilter.Queue {
+		if user, err := app.models.DB.GetUserByEmail(payload.Email); err == nil {
+			app.queueForReview(filtered, models.ReportTargetProfile, user.ID, user.ID)
+		}
+	}
+
 	// send the response
 	var resp struct {
 		OK      bool
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/raihan2bd/filmwise/contentfilter"
+	"github.com/raihan2bd/filmwise/models"
 )
 
 // readJSON reads json from request body into data. We only accept a single json value in the body
@@ -154,3 +156,143 @@ func (app *application) parseHeaderToken(r *http.Request) (int, error) {
 
 	return userID, nil
 }
+
+// readPagination reads the page and limit query parameters, falling back to the given per page limit
+func (app *application) readPagination(r *http.Request, perPage int) (int, int, error) {
+	queryValues := r.URL.Query()
+	page := defaultPage
+
+	// set up current page
+	if queryValues.Get("page") != "" {
+		p, err := strconv.Atoi(queryValues.Get("page"))
+		if err != nil || p <= 0 {
+			return 0, 0, errors.New("current page should be a positive number")
+		}
+		page = p
+	}
+
+	// set up per page limit
+	if queryValues.Get("limit") != "" {
+		pp, err := strconv.Atoi(queryValues.Get("limit"))
+		if err != nil || pp <= 0 {
+			return 0, 0, errors.New("per page limit should be a positive number")
+		}
+		perPage = pp
+	}
+
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return page, perPage, nil
+}
+
+// readLimit reads the limit query parameter of a listing without pages, it is capped at max
+func (app *application) readLimit(r *http.Request, limit, max int) (int, error) {
+	if r.URL.Query().Get("limit") != "" {
+		l, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || l <= 0 {
+			return 0, errors.New("limit should be a positive number")
+		}
+		limit = l
+	}
+
+	if limit > max {
+		limit = max
+	}
+
+	return limit, nil
+}
+
+// revealSpoilers tells whether spoilers should be shown, the spoilers query parameter (show or hide)
+// takes precedence over the preference of the logged in user. Spoilers are hidden by default.
+func (app *application) revealSpoilers(r *http.Request, userID int) bool {
+	switch r.URL.Query().Get("spoilers") {
+	case "show":
+		return true
+	case "hide":
+		return false
+	}
+
+	if userID > 0 {
+		prefs, err := app.models.DB.GetUserPreferences(userID)
+		if err == nil {
+			return prefs.ShowSpoilers
+		}
+	}
+
+	return false
+}
+
+// profileVisible writes a 404 and returns false unless the viewer can see the activity of the user, a private
+// profile is only seen by its owner and its accepted followers
+func (app *application) profileVisible(w http.ResponseWriter, userID, viewerID int) bool {
+	err := app.models.DB.CheckProfileVisible(userID, viewerID)
+	if err == nil {
+		return true
+	}
+
+	if !errors.Is(err, models.ErrProfileNotVisible) {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the user"), http.StatusInternalServerError)
+		return false
+	}
+
+	app.errorJSON(w, err, http.StatusNotFound)
+	return false
+}
+
+// certificationLimit returns the certification limit of the logged in user, nil when they set none. It
+// writes the error response and returns false when the preferences can't be loaded, so the limit is never
+// silently lifted
+func (app *application) certificationLimit(w http.ResponseWriter, userID int) (*models.CertificationLimit, bool) {
+	if userID <= 0 {
+		return nil, true
+	}
+
+	prefs, err := app.models.DB.GetUserPreferences(userID)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the preferences"), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return prefs.CertificationLimit(), true
+}
+
+// filterText runs user text through the content filter. It writes the error response and returns nil
+// when the text can't be saved, a user posting too often gets a 429
+func (app *application) filterText(w http.ResponseWriter, in contentfilter.Input) *contentfilter.Result {
+	res, err := app.filter.Run(in)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to check the text"), http.StatusInternalServerError)
+		return nil
+	}
+
+	if res.Action == contentfilter.Reject {
+		status := http.StatusBadRequest
+		if res.TooFast {
+			status = http.StatusTooManyRequests
+		}
+		app.errorJSON(w, res.Error(), status)
+		return nil
+	}
+
+	return res
+}
+
+// queueForReview hides a saved text the content filter queued and reports it to the moderators,
+// it returns true when the text was queued
+func (app *application) queueForReview(res *contentfilter.Result, targetType string, targetID, userID int) bool {
+	if res.Action != contentfilter.Queue {
+		return false
+	}
+
+	err := app.models.DB.QueueForReview(targetType, targetID, userID, strings.Join(res.Reasons, ", "))
+	if err != nil {
+		app.logger.Println(err)
+	}
+
+	return true
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"time"
+
+	"github.com/raihan2bd/filmwise/recommend"
+)
+
+// runEvery runs job now and then after every interval, for the life of the process
+func (app *application) runEvery(name string, interval time.Duration, job func() error) {
+	go func() {
+		for {
+			start := time.Now()
+			err := job()
+			if err != nil {
+				app.logger.Printf("%s: %v", name, err)
+			} else {
+				app.logger.Printf("%s: done in %s", name, time.Since(start).Round(time.Millisecond))
+			}
+
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// refreshRelatedMovies rebuilds the "more like this" movies
+func (app *application) refreshRelatedMovies() error {
+	_, err := app.models.DB.RefreshRelatedMovies(recommend.DefaultWeights)
+	return err
+}
+
+// recountEndedShadowMutes counts the comments of the users whose shadow mute ended again
+func (app *application) recountEndedShadowMutes() error {
+	_, err := app.models.DB.RecountEndedShadowMutes()
+	return err
+}
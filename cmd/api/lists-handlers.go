@@ -0,0 +1,455 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/contentfilter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// list payload
+type listPayload struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility"`
+}
+
+// list item payload
+type listItemPayload struct {
+	ListID  int `json:"list_id"`
+	MovieID int `json:"movie_id"`
+}
+
+// list order payload, the movies of the list in their new order
+type listOrderPayload struct {
+	ListID   int   `json:"list_id"`
+	MovieIDs []int `json:"movie_ids"`
+}
+
+// writeListError writes a list error with the status it calls for
+func (app *application) writeListError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrListNotFound), errors.Is(err, models.ErrListItemNotFound):
+		app.errorJSON(w, err, http.StatusNotFound)
+	case errors.Is(err, models.ErrListItemExists):
+		app.errorJSON(w, err, http.StatusConflict)
+	default:
+		app.errorJSON(w, err)
+	}
+}
+
+// Add or Update a list of the logged in user, a payload with an id updates the list
+func (app *application) addOrUpdateList(w http.ResponseWriter, r *http.Request) {
+	var payload listPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	payload.Name = strings.TrimSpace(payload.Name)
+	payload.Description = strings.TrimSpace(payload.Description)
+
+	// a new list is private unless told otherwise, an update keeps the visibility it has
+	if payload.Visibility == "" && r.Method != http.MethodPut {
+		payload.Visibility = models.ListPrivate
+	}
+
+	validator := validator.New()
+	validator.IsLength(payload.Name, "name", 1, 100)
+	validator.IsLength(payload.Description, "description", 0, 2000)
+	validator.Check(payload.Visibility == "" || contains([]string{models.ListPublic, models.ListUnlisted, models.ListPrivate}, payload.Visibility),
+		"visibility", "visibility should be one of public, unlisted or private")
+
+	if r.Method == http.MethodPut && payload.ID <= 0 {
+		validator.AddError("id", "invalid id!")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	// check the name and the description with the content filter, they may be masked
+	name := app.filterText(w, contentfilter.Input{Kind: contentfilter.KindList, Text: payload.Name})
+	if name == nil {
+		return
+	}
+
+	description := app.filterText(w, contentfilter.Input{Kind: contentfilter.KindList, Text: payload.Description})
+	if description == nil {
+		return
+	}
+
+	list := models.UserList{
+		ID:          payload.ID,
+		UserID:      userID,
+		Name:        name.Text,
+		Description: description.Text,
+		Visibility:  payload.Visibility,
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Slug    string `json:"slug,omitempty"`
+		Message string `json:"message"`
+	}
+
+	status := http.StatusCreated
+	if payload.ID > 0 {
+		err = app.models.DB.UpdateList(&list)
+		resp.ID = payload.ID
+		resp.Message = "List is updated successfully!"
+		status = http.StatusOK
+	} else {
+		resp.ID, resp.Slug, err = app.models.DB.InsertList(&list)
+		resp.Message = "List is added successfully!"
+	}
+
+	if err != nil {
+		app.writeListError(w, err)
+		return
+	}
+
+	// a list can't be hidden, a queued one is reported to the moderators with the profile of its owner
+	if !app.queueForReview(name, models.ReportTargetProfile, userID, userID) {
+		app.queueForReview(description, models.ReportTargetProfile, userID, userID)
+	}
+
+	resp.OK = true
+
+	err = app.writeJSON(w, status, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Delete a list of the logged in user
+func (app *application) deleteList(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	err = app.models.DB.DeleteList(id, userID)
+	if err != nil {
+		app.writeListError(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "list is successfully deleted!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get all the lists of the logged in user, whatever their visibility
+func (app *application) getMyLists(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	lists, err := app.models.DB.GetUserLists(userID, true)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the lists"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, lists, "lists")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the public lists of a user
+func (app *application) getUserLists(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := strconv.Atoi(params.ByName("user_id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid user id"))
+		return
+	}
+
+	// get viewerID from bareaer token
+	viewerID, _ := app.parseHeaderToken(r)
+
+	if !app.profileVisible(w, userID, viewerID) {
+		return
+	}
+
+	lists, err := app.models.DB.GetUserLists(userID, false)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the lists"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, lists, "lists")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get a list with its movies by its slug, a private list is only shown to its owner
+func (app *application) getSharedList(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	list, err := app.models.DB.GetListBySlug(params.ByName("slug"), cert)
+	if err != nil {
+		if !errors.Is(err, models.ErrListNotFound) {
+			app.logger.Println(err)
+		}
+		app.errorJSON(w, models.ErrListNotFound, http.StatusNotFound)
+		return
+	}
+
+	if list.UserID != userID {
+		if list.Visibility == models.ListPrivate {
+			app.errorJSON(w, models.ErrListNotFound, http.StatusNotFound)
+			return
+		}
+
+		err = app.models.DB.CountListView(list.ID)
+		if err != nil {
+			app.logger.Println(err)
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, list, "list")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the most viewed public lists
+func (app *application) getPopularLists(w http.ResponseWriter, r *http.Request) {
+	page, perPage, err := app.readPagination(r, defaultListsPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	lists, err := app.models.DB.GetPopularLists(page, perPage, userID, cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the lists"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, lists)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Add a movie at the end of a list of the logged in user
+func (app *application) addListItem(w http.ResponseWriter, r *http.Request) {
+	var payload listItemPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validator.Check(payload.ListID > 0, "list_id", "invalid list_id!")
+	validator.Check(payload.MovieID > 0, "movie_id", "invalid movie_id!")
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	id, err := app.models.DB.AddListItem(payload.ListID, userID, payload.MovieID)
+	if err != nil {
+		app.writeListError(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "Movie is added to the list successfully!"
+
+	err = app.writeJSON(w, http.StatusCreated, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Remove a movie from a list of the logged in user
+func (app *application) removeListItem(w http.ResponseWriter, r *http.Request) {
+	var payload listItemPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	if payload.ListID <= 0 || payload.MovieID <= 0 {
+		app.errorJSON(w, errors.New("invalid list_id or movie_id"))
+		return
+	}
+
+	err = app.models.DB.RemoveListItem(payload.ListID, userID, payload.MovieID)
+	if err != nil {
+		app.writeListError(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.ListID
+	resp.Message = "Movie is removed from the list successfully!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Put the movies of a list of the logged in user in a new order
+func (app *application) reorderListItems(w http.ResponseWriter, r *http.Request) {
+	var payload listOrderPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validator.Check(payload.ListID > 0, "list_id", "invalid list_id!")
+	validator.Check(len(payload.MovieIDs) <= models.ListMaxItems, "movie_ids", "too many movies")
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	err = app.models.DB.ReorderListItems(payload.ListID, userID, payload.MovieIDs)
+	if err != nil {
+		app.writeListError(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.ListID
+	resp.Message = "List is reordered successfully!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/raihan2bd/filmwise/contentfilter"
 	"github.com/raihan2bd/filmwise/models"
 )
 
@@ -28,6 +29,12 @@ type config struct {
 	jwt struct {
 		secret string
 	}
+	moderation struct {
+		hideThreshold int
+	}
+	jobs struct {
+		relatedInterval time.Duration
+	}
 }
 
 type AppStatus struct {
@@ -40,6 +47,7 @@ type application struct {
 	config config
 	logger *log.Logger
 	models models.Models
+	filter *contentfilter.Filter
 }
 
 func main() {
@@ -64,6 +72,14 @@ func main() {
 	if jwtSecret == "" {
 		jwtSecret = "jwt-secret"
 	}
+	hideThreshold := os.Getenv("REPORT_HIDE_THRESHOLD")
+	if hideThreshold == "" {
+		hideThreshold = "3"
+	}
+	relatedInterval := os.Getenv("RELATED_REFRESH_INTERVAL")
+	if relatedInterval == "" {
+		relatedInterval = "0"
+	}
 
 	// initialize config
 	portNum, err := strconv.Atoi(port)
@@ -75,6 +91,18 @@ func main() {
 	cfg.db.dsn = dsn
 	cfg.jwt.secret = jwtSecret
 
+	// reported content is hidden once this many users report it
+	cfg.moderation.hideThreshold, err = strconv.Atoi(hideThreshold)
+	if err != nil || cfg.moderation.hideThreshold < 1 {
+		log.Fatal("REPORT_HIDE_THRESHOLD should be a positive number")
+	}
+
+	// the related movies are rebuilt this often, zero leaves it to the cli
+	cfg.jobs.relatedInterval, err = time.ParseDuration(relatedInterval)
+	if err != nil || cfg.jobs.relatedInterval < 0 {
+		log.Fatal("RELATED_REFRESH_INTERVAL should be a duration like 6h")
+	}
+
 	// setup logger
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
@@ -97,6 +125,23 @@ func main() {
 		models: models.NewModels(db, cld),
 	}
 
+	// setup the content filter for comments, reviews and display names
+	filterConfig, err := contentfilter.LoadConfig(os.Getenv("CONTENT_FILTER_CONFIG"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	app.filter, err = contentfilter.FromConfig(filterConfig, &app.models.DB)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// start the background jobs, the comment counts catch up with the shadow mutes that ended within minutes
+	app.runEvery("recount ended shadow mutes", 10*time.Minute, app.recountEndedShadowMutes)
+	if cfg.jobs.relatedInterval > 0 {
+		app.runEvery("refresh related movies", cfg.jobs.relatedInterval, app.refreshRelatedMovies)
+	}
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.port),
 		Handler:      app.routes(),
@@ -1,16 +1,53 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/raihan2bd/filmwise/models"
 )
 
 // add custom type for context key
 type userIDKey string
 
+// user types
+const (
+	userTypeAdmin     = "admin"
+	userTypeModerator = "moderator"
+	userTypeUser      = "user"
+)
+
+// rejectSanctioned writes an error and returns true when the user is banned or suspended. It runs on
+// every authenticated request so tokens issued before the sanction stop working right away
+func (app *application) rejectSanctioned(w http.ResponseWriter, userID int) bool {
+	sanction, err := app.models.DB.GetAccessSanction(userID)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("internal server error"), http.StatusInternalServerError)
+		return true
+	}
+
+	if sanction == nil {
+		return false
+	}
+
+	if sanction.Kind == models.SanctionBan || sanction.EndsAt == nil {
+		app.errorJSON(w, fmt.Errorf("your account has been banned: %s", sanction.Reason), http.StatusForbidden)
+	} else {
+		app.errorJSON(w, fmt.Errorf("your account is suspended until %s: %s", sanction.EndsAt.Format("2006-01-02 15:04"), sanction.Reason), http.StatusForbidden)
+	}
+
+	return true
+}
+
 // enableCORS adds the Access-Control-Allow-Origin header to all responses.
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +95,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		// check user_type is allowed
-		if claims.UserType == "admin" || claims.UserType == "user" {
+		if claims.UserType == userTypeAdmin || claims.UserType == userTypeModerator || claims.UserType == userTypeUser {
 			// add user_id to context
 			userID, err := strconv.Atoi(claims.Subject)
 			if err != nil {
@@ -66,6 +103,10 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 				return
 			}
 
+			if app.rejectSanctioned(w, userID) {
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), userIDKey("user_id"), userID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 			// next.ServeHTTP(w, r)
@@ -117,7 +158,7 @@ func (app *application) adminAuth(next http.Handler) http.Handler {
 		}
 
 		// check user_type is allowed
-		if claims.UserType == "admin" {
+		if claims.UserType == userTypeAdmin {
 			// add user_id to context
 			userID, err := strconv.Atoi(claims.Subject)
 			if err != nil {
@@ -125,6 +166,10 @@ func (app *application) adminAuth(next http.Handler) http.Handler {
 				return
 			}
 
+			if app.rejectSanctioned(w, userID) {
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), userIDKey("user_id"), userID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 			// next.ServeHTTP(w, r)
@@ -135,3 +180,116 @@ func (app *application) adminAuth(next http.Handler) http.Handler {
 
 	}) // end of http.HandlerFunc
 }
+
+// moderatorAuth checks whether a request is coming from a moderator or an admin.
+func (app *application) moderatorAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		authHeader := r.Header.Get("Authorization")
+
+		// if auth header is empty then return error
+		if authHeader == "" {
+			app.errorJSON(w, errors.New("authorization header is required"), http.StatusUnauthorized)
+			return
+		}
+
+		headerParts := strings.Split(authHeader, " ")
+
+		// if auth header is not two parts or doesn't include Bearer then return error
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.errorJSON(w, errors.New("invalid auth header"), http.StatusUnauthorized)
+			return
+		}
+
+		// parse token, and return claims if there is not error
+		claims, err := app.verifyToken(headerParts[1])
+		if err != nil {
+			app.errorJSON(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		// check user_type is allowed
+		if claims.UserType != userTypeAdmin && claims.UserType != userTypeModerator {
+			app.errorJSON(w, errors.New("unauthorized - user does not have permission"), http.StatusForbidden)
+			return
+		}
+
+		// add user_id to context
+		userID, err := strconv.Atoi(claims.Subject)
+		if err != nil {
+			app.errorJSON(w, errors.New("unauthorized - user does not valid"), http.StatusUnauthorized)
+			return
+		}
+
+		if app.rejectSanctioned(w, userID) {
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey("user_id"), userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}) // end of http.HandlerFunc
+}
+
+// cachedResponse buffers a response so cacheable can tag it before it is sent
+type cachedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *cachedResponse) Header() http.Header { return c.header }
+
+func (c *cachedResponse) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(b)
+}
+
+func (c *cachedResponse) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+// cacheable lets clients and proxies cache successful responses of a public endpoint for maxAge. The responses
+// are tagged with an ETag of their body, a request with a matching If-None-Match gets a 304 without the body
+func (app *application) cacheable(maxAge time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := &cachedResponse{header: w.Header()}
+		next.ServeHTTP(resp, r)
+
+		if resp.status == 0 {
+			resp.status = http.StatusOK
+		}
+
+		if resp.status == http.StatusOK {
+			sum := sha256.Sum256(resp.body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+			// the certification limit of a logged in user changes the response, only their browser can keep it
+			visibility := "public"
+			if r.Header.Get("Authorization") != "" {
+				visibility = "private"
+			}
+
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(maxAge.Seconds())))
+			w.Header().Set("Vary", "Authorization")
+
+			for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+				match = strings.TrimPrefix(strings.TrimSpace(match), "W/")
+				if match == etag || match == "*" {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+		} else {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+
+		w.WriteHeader(resp.status)
+		w.Write(resp.body.Bytes())
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+)
+
+// Delete the rating of the logged in user for a movie
+func (app *application) deleteRating(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	movieID, err := strconv.Atoi(ps.ByName("movie_id"))
+	if err != nil || movieID <= 0 {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	id, err := app.models.DB.DeleteRating(movieID, userID)
+	switch {
+	case errors.Is(err, models.ErrRatingNotFound):
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrRatingHasReview):
+		app.errorJSON(w, err, http.StatusConflict)
+		return
+	case err != nil:
+		app.logger.Println(err)
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "Rating is deleted successfully!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the movies rated by the logged in user, sorted by the sort query parameter
+func (app *application) getMyRatings(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultRatingsPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// set up sort option
+	sortBy := r.URL.Query().Get("sort")
+	switch sortBy {
+	case "":
+		sortBy = models.RatingSortDate
+	case models.RatingSortDate, models.RatingSortScore:
+	default:
+		app.errorJSON(w, errors.New("sort should be one of date or score"))
+		return
+	}
+
+	ratings, err := app.models.DB.GetUserRatings(userID, page, perPage, sortBy)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the ratings"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, ratings)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+)
+
+// Get the movies like a movie, the limit query parameter sets how many
+func (app *application) getRelatedMovies(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id parameter"))
+		return
+	}
+
+	limit, err := app.readLimit(r, defaultRelatedMovies, models.RelatedMoviesPerMovie)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	movies, err := app.models.DB.GetRelatedMovies(id, limit, cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the related movies"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, movies, "movies")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the movies recommended to the logged in user, the limit query parameter sets how many
+func (app *application) getRecommendations(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	limit, err := app.readLimit(r, defaultRecommendations, maxPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	recommendations, err := app.models.DB.GetRecommendations(userID, limit, cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the recommendations"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, recommendations, "movies")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// report payload
+type reportPayload struct {
+	TargetType string `json:"target_type"`
+	TargetID   int    `json:"target_id"`
+	Reason     string `json:"reason"`
+	Note       string `json:"note"`
+}
+
+// resolve report payload
+type resolveReportPayload struct {
+	ReportID int    `json:"report_id"`
+	Action   string `json:"action"`
+	Note     string `json:"note"`
+}
+
+// contains tells if value is one of values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// reportErrorStatus returns the response status for an error of the moderation queue
+func reportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrDuplicateReport),
+		errors.Is(err, models.ErrReportClaimed),
+		errors.Is(err, models.ErrReportResolved):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrStaffBan):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Report a comment, a review or a profile
+func (app *application) addReport(w http.ResponseWriter, r *http.Request) {
+	var payload reportPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	payload.Note = strings.TrimSpace(payload.Note)
+
+	validator := validator.New()
+	validator.Check(contains([]string{models.ReportTargetComment, models.ReportTargetReview, models.ReportTargetProfile}, payload.TargetType),
+		"target_type", "target_type should be one of comment, review or profile")
+	validator.Check(contains(models.ReportReasons, payload.Reason),
+		"reason", "reason should be one of "+strings.Join(models.ReportReasons, ", "))
+
+	if payload.TargetID <= 0 {
+		validator.AddError("target_id", "invalid target_id!")
+	}
+
+	// other needs a note to tell what is wrong
+	if payload.Reason == "other" {
+		validator.IsLength(payload.Note, "note", 10, 500)
+	} else if len(payload.Note) > 500 {
+		validator.AddError("note", "note should be at most 500 characters long")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	// check if the target exists and who it belongs to
+	ownerID, err := app.models.DB.ReportTargetOwner(payload.TargetType, payload.TargetID)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	if ownerID == userID {
+		app.errorJSON(w, errors.New("you can't report yourself"))
+		return
+	}
+
+	report := models.Report{
+		TargetType:   payload.TargetType,
+		TargetID:     payload.TargetID,
+		TargetUserID: ownerID,
+		ReporterID:   userID,
+		Reason:       payload.Reason,
+		Note:         payload.Note,
+	}
+
+	id, hidden, err := app.models.DB.InsertReport(&report, app.config.moderation.hideThreshold)
+	if err != nil {
+		app.errorJSON(w, err, reportErrorStatus(err))
+		return
+	}
+
+	if hidden {
+		app.logger.Printf("%s %d is hidden after reaching the report threshold", report.TargetType, report.TargetID)
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "Thanks, the report is sent to the moderators!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get a page of the moderation queue
+func (app *application) getReports(w http.ResponseWriter, r *http.Request) {
+	page, perPage, err := app.readPagination(r, defaultPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	filter := models.ReportFilter{
+		Status:     r.URL.Query().Get("status"),
+		TargetType: r.URL.Query().Get("target_type"),
+	}
+
+	if filter.Status != "" && !contains([]string{models.ReportStatusOpen, models.ReportStatusClaimed, models.ReportStatusResolved}, filter.Status) {
+		app.errorJSON(w, errors.New("status should be one of open, claimed or resolved"))
+		return
+	}
+
+	if filter.TargetType != "" && !contains([]string{models.ReportTargetComment, models.ReportTargetReview, models.ReportTargetProfile}, filter.TargetType) {
+		app.errorJSON(w, errors.New("target_type should be one of comment, review or profile"))
+		return
+	}
+
+	reports, err := app.models.DB.GetReports(page, perPage, &filter)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the reports"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, reports)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Claim a report so other moderators know it is taken
+func (app *application) claimReport(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	// get moderator id from context
+	moderatorID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	err = app.models.DB.ClaimReport(id, moderatorID)
+	if err != nil {
+		app.errorJSON(w, err, reportErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "report is successfully claimed!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Resolve a report with dismiss, hide, delete, warn or ban
+func (app *application) resolveReport(w http.ResponseWriter, r *http.Request) {
+	var payload resolveReportPayload
+
+	// get moderator id from context
+	moderatorID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	payload.Note = strings.TrimSpace(payload.Note)
+
+	validator := validator.New()
+	validator.Check(contains([]string{models.ModerationDismiss, models.ModerationHide, models.ModerationDelete, models.ModerationWarn, models.ModerationBan}, payload.Action),
+		"action", "action should be one of dismiss, hide, delete, warn or ban")
+
+	if payload.ReportID <= 0 {
+		validator.AddError("report_id", "invalid report_id!")
+	}
+
+	// taking action against a user needs a note for the audit trail
+	if payload.Action != models.ModerationDismiss {
+		validator.IsLength(payload.Note, "note", 3, 255)
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	err = app.models.DB.ResolveReport(payload.ReportID, moderatorID, payload.Action, payload.Note)
+	if err != nil {
+		app.errorJSON(w, err, reportErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.ReportID
+	resp.Message = "report is successfully resolved!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Get the audit trail of moderation decisions on a comment, a review or a profile
+func (app *application) getModerationActions(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	targetType := ps.ByName("target_type")
+	if !contains([]string{models.ReportTargetComment, models.ReportTargetReview, models.ReportTargetProfile}, targetType) {
+		app.errorJSON(w, errors.New("target type should be one of comment, review or profile"))
+		return
+	}
+
+	targetID, err := strconv.Atoi(ps.ByName("target_id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid target id"))
+		return
+	}
+
+	actions, err := app.models.DB.GetModerationActions(targetType, targetID)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the moderation actions"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, actions, "actions")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
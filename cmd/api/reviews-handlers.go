@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/contentfilter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// review payload
+type reviewPayload struct {
+	MovieID   int    `json:"movie_id"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	IsSpoiler bool   `json:"is_spoiler"`
+}
+
+// Add or Update the review of the user for a movie
+func (app *application) addOrUpdateReview(w http.ResponseWriter, r *http.Request) {
+	var payload reviewPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	if userID <= 0 {
+		app.errorJSON(w, errors.New("authentication failed"), http.StatusUnauthorized)
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	payload.Title = strings.TrimSpace(payload.Title)
+	payload.Body = strings.TrimSpace(payload.Body)
+
+	validator := validator.New()
+	validator.IsLength(payload.Title, "title", 3, 150)
+	validator.IsLength(payload.Body, "body", 50, 10000)
+
+	if payload.MovieID <= 0 {
+		validator.AddError("movie_id", "invalid movie_id!")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	// check if the movie exists
+	_, err = app.models.DB.Get(payload.MovieID)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	// a user can only write one review per movie, writing again updates it
+	reviewID, _ := app.models.DB.FindReview(payload.MovieID, userID)
+
+	// check the title and the body with the content filter as one text so a review counts once, masking
+	// keeps the length of the text so the title and the body are split back where they were joined
+	filtered := app.filterText(w, contentfilter.Input{Kind: contentfilter.KindReview, UserID: userID, ID: reviewID, Text: payload.Title + "\n" + payload.Body})
+	if filtered == nil {
+		return
+	}
+
+	text := []rune(filtered.Text)
+	titleLen := len([]rune(payload.Title))
+
+	review := models.Review{
+		ID:        reviewID,
+		MovieID:   payload.MovieID,
+		UserID:    userID,
+		Title:     string(text[:titleLen]),
+		Body:      string(text[titleLen+1:]),
+		IsSpoiler: payload.IsSpoiler,
+	}
+
+	var id int
+	respMsg := "Review is added successfully!"
+
+	if reviewID > 0 {
+		id, err = app.models.DB.UpdateReview(&review)
+		respMsg = "Review is updated successfully!"
+	} else {
+		id, err = app.models.DB.InsertReview(&review)
+	}
+
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	if app.queueForReview(filtered, models.ReportTargetReview, id, userID) {
+		respMsg = "Review is saved and waits for a review by the moderators!"
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = respMsg
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Delete a review of the user
+func (app *application) deleteReview(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	review, err := app.models.DB.GetReview(id)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid review id"))
+		return
+	}
+
+	if review.UserID != userID {
+		app.errorJSON(w, errors.New("you can only delete your own reviews"), http.StatusForbidden)
+		return
+	}
+
+	err = app.models.DB.DeleteReview(id)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "review is successfully deleted!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Get one review
+func (app *application) getOneReview(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id parameter"))
+		return
+	}
+
+	review, err := app.models.DB.GetReview(id)
+	if err != nil {
+		app.errorJSON(w, errors.New("failed to fetch the review"), http.StatusNotFound)
+		return
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+
+	// a review hidden by moderators is only shown to its author
+	if review.IsHidden && review.UserID != userID {
+		app.errorJSON(w, errors.New("failed to fetch the review"), http.StatusNotFound)
+		return
+	}
+
+	review.RenderSpoilers(app.revealSpoilers(r, userID))
+
+	err = app.writeJSON(w, http.StatusOK, review, "review")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Get all reviews of a movie
+func (app *application) getMovieReviews(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	movieID, err := strconv.Atoi(params.ByName("movie_id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	app.listReviews(w, r, &models.ReviewFilter{MovieID: movieID})
+}
+
+// Get all reviews written by a user
+func (app *application) getUserReviews(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := strconv.Atoi(params.ByName("user_id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid user id"))
+		return
+	}
+
+	// get viewerID from bareaer token
+	viewerID, _ := app.parseHeaderToken(r)
+
+	if !app.profileVisible(w, userID, viewerID) {
+		return
+	}
+
+	app.listReviews(w, r, &models.ReviewFilter{UserID: userID})
+}
+
+// listReviews writes a page of reviews matching the filter, sorted by the sort query parameter
+func (app *application) listReviews(w http.ResponseWriter, r *http.Request, filter *models.ReviewFilter) {
+	page, perPage, err := app.readPagination(r, defaultReviewsPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// set up sort option
+	switch sortBy := r.URL.Query().Get("sort"); sortBy {
+	case "", models.ReviewSortNewest:
+		filter.SortBy = models.ReviewSortNewest
+	case models.ReviewSortOldest, models.ReviewSortRatingHigh, models.ReviewSortRatingLow:
+		filter.SortBy = sortBy
+	default:
+		app.errorJSON(w, errors.New("sort should be one of newest, oldest, rating_high or rating_low"))
+		return
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+	filter.ViewerID = userID
+
+	reviews, err := app.models.DB.GetReviews(page, perPage, filter)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the reviews"), http.StatusInternalServerError)
+		return
+	}
+
+	reveal := app.revealSpoilers(r, userID)
+	for _, review := range reviews.Reviews {
+		review.RenderSpoilers(reveal)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, reviews)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
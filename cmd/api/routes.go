@@ -28,30 +28,125 @@ func (app *application) routes() http.Handler {
 
 	// initialize admin middleware
 	secureAdmin := alice.New(app.adminAuth)
+
+	// initialize moderator middleware, admins are allowed as well
+	secureModerator := alice.New(app.moderatorAuth)
 	// public routes
 	router.HandlerFunc(http.MethodGet, "/status", app.GetStatus)
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.getAllMoviesByFilter)
 	router.HandlerFunc(http.MethodGet, "/v1/movies/feature", app.getFeatureMovies)
 	router.HandlerFunc(http.MethodGet, "/v1/movies/all", app.getAllMovies)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/genre/:genre_id", app.getAllMoviesByGenre)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/trending", app.getTrendingMovies)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/genre/:genre", app.getAllMoviesByGenre)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/tag/:tag", app.getMoviesByTag)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/studio/:studio", app.getMoviesByStudio)
 	router.HandlerFunc(http.MethodGet, "/v1/genres", app.getAllGenres)
+	router.HandlerFunc(http.MethodGet, "/v1/genres/:slug", app.getGenre)
+	router.HandlerFunc(http.MethodGet, "/v1/tags", app.getPopularTags)
+	router.HandlerFunc(http.MethodGet, "/v1/tags/:slug", app.getTag)
+	router.HandlerFunc(http.MethodGet, "/v1/certifications", app.getCertifications)
+	router.HandlerFunc(http.MethodGet, "/v1/studios", app.getStudios)
+	router.HandlerFunc(http.MethodGet, "/v1/studios/:slug", app.getStudio)
 	router.HandlerFunc(http.MethodGet, "/v1/movie/get_one/:id", app.getOneMovie)
+	router.HandlerFunc(http.MethodGet, "/v1/movie/related/:id", app.getRelatedMovies)
+	router.HandlerFunc(http.MethodGet, "/v1/movie/suggested_tags/:id", app.getSuggestedTags)
+	router.HandlerFunc(http.MethodGet, "/v1/movie/comments/list/:movie_id", app.getMovieComments)
+	router.HandlerFunc(http.MethodGet, "/v1/movie/comments/thread/:id", app.getCommentThread)
+	router.HandlerFunc(http.MethodGet, "/v1/reviews/get_one/:id", app.getOneReview)
+	router.HandlerFunc(http.MethodGet, "/v1/reviews/movie/:movie_id", app.getMovieReviews)
+	router.HandlerFunc(http.MethodGet, "/v1/reviews/user/:user_id", app.getUserReviews)
+	router.HandlerFunc(http.MethodGet, "/v1/lists/popular", app.getPopularLists)
+	router.HandlerFunc(http.MethodGet, "/v1/lists/shared/:slug", app.getSharedList)
+	router.HandlerFunc(http.MethodGet, "/v1/lists/user/:user_id", app.getUserLists)
+
+	// public routes that clients and proxies may cache
+	router.Handler(http.MethodGet, "/v1/home", app.cacheable(publicCacheMaxAge, http.HandlerFunc(app.getHome)))
+	router.Handler(http.MethodGet, "/v1/collections", app.cacheable(publicCacheMaxAge, http.HandlerFunc(app.getCollections)))
+	router.Handler(http.MethodGet, "/v1/collections/:slug", app.cacheable(publicCacheMaxAge, http.HandlerFunc(app.getCollection)))
 
 	router.HandlerFunc(http.MethodPost, "/v1/user/signup/", app.signUp)
 	router.HandlerFunc(http.MethodPost, "/v1/user/login/", app.loginUser)
 
 	/* private routes for user */
 
+	// routes for the logged in user
+	router.GET("/v1/me/preferences", app.wrap(secure.ThenFunc(app.getPreferences)))
+	router.PUT("/v1/me/preferences", app.wrap(secure.ThenFunc(app.updatePreferences)))
+	router.GET("/v1/me/ratings", app.wrap(secure.ThenFunc(app.getMyRatings)))
+	router.GET("/v1/me/lists", app.wrap(secure.ThenFunc(app.getMyLists)))
+	router.GET("/v1/me/watchlist", app.wrap(secure.ThenFunc(app.getWatchlist)))
+	router.GET("/v1/me/diary", app.wrap(secure.ThenFunc(app.getDiary)))
+	router.GET("/v1/me/favorites", app.wrap(secure.ThenFunc(app.getMyFavorites)))
+	router.GET("/v1/me/followers", app.wrap(secure.ThenFunc(app.getFollowers)))
+	router.GET("/v1/me/following", app.wrap(secure.ThenFunc(app.getFollowing)))
+	router.GET("/v1/me/follow_requests", app.wrap(secure.ThenFunc(app.getFollowRequests)))
+	router.GET("/v1/me/feed", app.wrap(secure.ThenFunc(app.getFeed)))
+	router.GET("/v1/me/recommendations", app.wrap(secure.ThenFunc(app.getRecommendations)))
+
 	// routes for ratings
 	router.POST("/v1/rating/add", app.wrap(secure.ThenFunc(app.addOrUpdateRating)))
+	router.DELETE("/v1/ratings/:movie_id", app.wrap(secure.ThenFunc(app.deleteRating)))
 
 	// user private routes to manage comments
 	router.POST("/v1/movie/comments/add", app.wrap(secure.ThenFunc(app.addOrUpdateComment)))
 	router.PUT("/v1/movie/comments/update", app.wrap(secure.ThenFunc(app.addOrUpdateComment)))
 	router.GET("/v1/movie/comments/delete/:id", app.wrap(secure.ThenFunc(app.deleteComment)))
+	router.POST("/v1/movie/comments/react", app.wrap(secure.ThenFunc(app.toggleCommentReaction)))
+
+	// user private routes to manage reviews
+	router.POST("/v1/reviews/add", app.wrap(secure.ThenFunc(app.addOrUpdateReview)))
+	router.GET("/v1/reviews/delete/:id", app.wrap(secure.ThenFunc(app.deleteReview)))
 
 	// routes for favorites
 	router.GET("/v1/favorite/:id", app.wrap(secure.ThenFunc(app.addOrUpdateFavorite)))
+	router.PUT("/v1/favorites/:movie_id", app.wrap(secure.ThenFunc(app.putFavorite)))
+	router.DELETE("/v1/favorites/:movie_id", app.wrap(secure.ThenFunc(app.deleteFavorite)))
+
+	// user private routes to manage lists
+	router.POST("/v1/lists/add", app.wrap(secure.ThenFunc(app.addOrUpdateList)))
+	router.PUT("/v1/lists/update", app.wrap(secure.ThenFunc(app.addOrUpdateList)))
+	router.GET("/v1/lists/delete/:id", app.wrap(secure.ThenFunc(app.deleteList)))
+	router.POST("/v1/lists/items/add", app.wrap(secure.ThenFunc(app.addListItem)))
+	router.POST("/v1/lists/items/remove", app.wrap(secure.ThenFunc(app.removeListItem)))
+	router.POST("/v1/lists/items/reorder", app.wrap(secure.ThenFunc(app.reorderListItems)))
+
+	// user private routes to manage the watchlist and the diary
+	router.PUT("/v1/watchlist/:movie_id", app.wrap(secure.ThenFunc(app.addToWatchlist)))
+	router.DELETE("/v1/watchlist/:movie_id", app.wrap(secure.ThenFunc(app.removeFromWatchlist)))
+	router.POST("/v1/diary/add", app.wrap(secure.ThenFunc(app.addOrUpdateDiaryEntry)))
+	router.PUT("/v1/diary/update", app.wrap(secure.ThenFunc(app.addOrUpdateDiaryEntry)))
+	router.GET("/v1/diary/delete/:id", app.wrap(secure.ThenFunc(app.deleteDiaryEntry)))
+
+	// user private routes to follow other users
+	router.PUT("/v1/follows/:user_id", app.wrap(secure.ThenFunc(app.followUser)))
+	router.DELETE("/v1/follows/:user_id", app.wrap(secure.ThenFunc(app.unfollowUser)))
+	router.POST("/v1/follow_requests/respond", app.wrap(secure.ThenFunc(app.respondFollowRequest)))
+
+	// routes for reports
+	router.POST("/v1/reports/add", app.wrap(secure.ThenFunc(app.addReport)))
+
+	// routes for tag suggestions
+	router.POST("/v1/tags/suggest", app.wrap(secure.ThenFunc(app.suggestTag)))
+
+	// moderator routes to manage comments
+	router.POST("/v1/moderator/comments/remove", app.wrap(secureModerator.ThenFunc(app.removeComment)))
+	router.GET("/v1/moderator/comments/restore/:id", app.wrap(secureModerator.ThenFunc(app.restoreComment)))
+	router.GET("/v1/moderator/comments/history/:id", app.wrap(secureModerator.ThenFunc(app.getCommentHistory)))
+
+	// moderator routes for the moderation queue
+	router.GET("/v1/moderator/reports/list", app.wrap(secureModerator.ThenFunc(app.getReports)))
+	router.GET("/v1/moderator/reports/claim/:id", app.wrap(secureModerator.ThenFunc(app.claimReport)))
+	router.POST("/v1/moderator/reports/resolve", app.wrap(secureModerator.ThenFunc(app.resolveReport)))
+	router.GET("/v1/moderator/reports/actions/:target_type/:target_id", app.wrap(secureModerator.ThenFunc(app.getModerationActions)))
+
+	// moderator routes to review the tag suggestions
+	router.GET("/v1/moderator/tags/suggestions", app.wrap(secureModerator.ThenFunc(app.getTagSuggestions)))
+	router.POST("/v1/moderator/tags/review", app.wrap(secureModerator.ThenFunc(app.reviewTagSuggestion)))
+
+	// admin routes to manage user sanctions
+	router.POST("/v1/admin/users/sanctions/add", app.wrap(secureAdmin.ThenFunc(app.addSanction)))
+	router.POST("/v1/admin/users/sanctions/revoke", app.wrap(secureAdmin.ThenFunc(app.revokeSanction)))
+	router.GET("/v1/admin/users/sanctions/list/:user_id", app.wrap(secureAdmin.ThenFunc(app.getUserSanctions)))
 
 	// private routes for admin
 	router.POST("/v1/images/upload", app.wrap(secureAdmin.ThenFunc(app.uploadImage)))
@@ -67,5 +162,29 @@ func (app *application) routes() http.Handler {
 	router.GET("/v1/admin/movie/delete/:id", app.wrap(secureAdmin.ThenFunc(app.deleteMovie)))
 	router.GET("/v1/image/:filename", app.serveImages)
 
+	// admin routes to manage the collections
+	router.GET("/v1/admin/collections/list", app.wrap(secureAdmin.ThenFunc(app.getAllCollections)))
+	router.POST("/v1/admin/collections/add", app.wrap(secureAdmin.ThenFunc(app.addOrUpdateCollection)))
+	router.PUT("/v1/admin/collections/edit", app.wrap(secureAdmin.ThenFunc(app.addOrUpdateCollection)))
+	router.GET("/v1/admin/collections/delete/:id", app.wrap(secureAdmin.ThenFunc(app.deleteCollection)))
+	router.POST("/v1/admin/collections/movies", app.wrap(secureAdmin.ThenFunc(app.setCollectionMovies)))
+
+	// admin routes to manage the tags
+	router.POST("/v1/admin/movie/tags/add", app.wrap(secureAdmin.ThenFunc(app.addMovieTag)))
+	router.POST("/v1/admin/movie/tags/remove", app.wrap(secureAdmin.ThenFunc(app.removeMovieTag)))
+	router.GET("/v1/admin/tags/delete/:id", app.wrap(secureAdmin.ThenFunc(app.deleteTag)))
+
+	// admin routes to manage the studios
+	router.PUT("/v1/admin/studios/edit", app.wrap(secureAdmin.ThenFunc(app.updateStudio)))
+	router.GET("/v1/admin/studios/delete/:id", app.wrap(secureAdmin.ThenFunc(app.deleteStudio)))
+
+	// admin route to set the certifications of a movie
+	router.POST("/v1/admin/movie/certifications", app.wrap(secureAdmin.ThenFunc(app.setMovieCertifications)))
+
+	// admin routes to pin movies on the featured movies
+	router.GET("/v1/admin/featured/list", app.wrap(secureAdmin.ThenFunc(app.getPinnedMovies)))
+	router.POST("/v1/admin/featured/pin", app.wrap(secureAdmin.ThenFunc(app.pinFeaturedMovie)))
+	router.GET("/v1/admin/featured/unpin/:movie_id", app.wrap(secureAdmin.ThenFunc(app.unpinFeaturedMovie)))
+
 	return app.enableCORS(router)
 }
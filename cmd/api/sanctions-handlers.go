@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// sanction payload
+type sanctionPayload struct {
+	UserID        int    `json:"user_id"`
+	Kind          string `json:"kind"`
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"` // zero makes a shadow mute permanent
+}
+
+// revoke sanction payload
+type revokeSanctionPayload struct {
+	SanctionID int    `json:"sanction_id"`
+	Reason     string `json:"reason"`
+}
+
+// Suspend, ban or shadow mute a user
+func (app *application) addSanction(w http.ResponseWriter, r *http.Request) {
+	var payload sanctionPayload
+
+	// get admin id from context
+	adminID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	payload.Reason = strings.TrimSpace(payload.Reason)
+
+	validator := validator.New()
+	validator.IsLength(payload.Reason, "reason", 3, 255)
+	validator.Check(contains([]string{models.SanctionSuspension, models.SanctionBan, models.SanctionShadowMute}, payload.Kind),
+		"kind", "kind should be one of suspension, ban or shadow_mute")
+
+	if payload.UserID <= 0 {
+		validator.AddError("user_id", "invalid user_id!")
+	}
+
+	switch payload.Kind {
+	case models.SanctionSuspension:
+		validator.Check(payload.DurationHours > 0, "duration_hours", "a suspension needs a duration")
+	case models.SanctionBan:
+		validator.Check(payload.DurationHours == 0, "duration_hours", "a ban is permanent, use a suspension instead")
+	default:
+		validator.Check(payload.DurationHours >= 0, "duration_hours", "invalid duration_hours!")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	user, err := app.models.DB.GetUser(payload.UserID)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid user id"), http.StatusNotFound)
+		return
+	}
+
+	if user.ID == adminID || user.UserType == userTypeAdmin {
+		app.errorJSON(w, errors.New("admins can't be sanctioned"), http.StatusForbidden)
+		return
+	}
+
+	sanction := models.Sanction{
+		UserID:   user.ID,
+		Kind:     payload.Kind,
+		Reason:   payload.Reason,
+		IssuedBy: adminID,
+	}
+
+	if payload.DurationHours > 0 {
+		endsAt := time.Now().Add(time.Duration(payload.DurationHours) * time.Hour)
+		sanction.EndsAt = &endsAt
+	}
+
+	id, err := app.models.DB.InsertSanction(&sanction)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "sanction is successfully added!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get all sanctions of a user, the active and the past ones
+func (app *application) getUserSanctions(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	userID, err := strconv.Atoi(ps.ByName("user_id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid user id"))
+		return
+	}
+
+	sanctions, err := app.models.DB.GetUserSanctions(userID)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the sanctions"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, sanctions, "sanctions")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Lift a sanction before it ends
+func (app *application) revokeSanction(w http.ResponseWriter, r *http.Request) {
+	var payload revokeSanctionPayload
+
+	// get admin id from context
+	adminID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	payload.Reason = strings.TrimSpace(payload.Reason)
+
+	validator := validator.New()
+	validator.IsLength(payload.Reason, "reason", 3, 255)
+
+	if payload.SanctionID <= 0 {
+		validator.AddError("sanction_id", "invalid sanction_id!")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		return
+	}
+
+	err = app.models.DB.RevokeSanction(payload.SanctionID, adminID, payload.Reason)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.SanctionID
+	resp.Message = "sanction is successfully revoked!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// studioErrorStatus returns the response status for an error of the studios
+func studioErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrStudioNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrStudioNameTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// Get the studios with the most movies, the s query parameter searches them by name and limit sets how many
+func (app *application) getStudios(w http.ResponseWriter, r *http.Request) {
+	limit, err := app.readLimit(r, defaultStudios, maxPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	studios, err := app.models.DB.GetStudios(r.URL.Query().Get("s"), limit)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the studios"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, studios, "studios")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get a studio with the number of its movies
+func (app *application) getStudio(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	studio, err := app.models.DB.GetStudioBySlug(params.ByName("slug"))
+	if err != nil {
+		if !errors.Is(err, models.ErrStudioNotFound) {
+			app.logger.Println(err)
+			app.errorJSON(w, errors.New("failed to fetch the studio"), http.StatusInternalServerError)
+			return
+		}
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, studio, "studio")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the movies of a studio
+func (app *application) getMoviesByStudio(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	studio, err := app.models.DB.GetStudioBySlug(params.ByName("studio"))
+	if err != nil {
+		app.errorJSON(w, models.ErrStudioNotFound, http.StatusNotFound)
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	filter := models.MovieFilter{
+		FilterByStudio: studio.ID,
+		OrderBy:        r.URL.Query().Get("order_by"),
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+	filter.Certification = cert
+
+	movies, err := app.models.DB.GetAllMoviesByFilter(page, perPage, &filter, userID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, movies)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Rename a studio
+func (app *application) updateStudio(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validator.Check(payload.ID > 0, "id", "invalid id!")
+	validator.IsLength(models.TagName(payload.Name), "name", 2, 100)
+	validator.Check(models.StudioSlug(payload.Name) != "", "name", "the name should have letters or digits")
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	_, err = app.models.DB.UpdateStudio(payload.ID, payload.Name)
+	if err != nil {
+		app.errorJSON(w, err, studioErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.ID
+	resp.Message = "studio is successfully updated!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Delete a studio, it is taken off its movies
+func (app *application) deleteStudio(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil || id <= 0 {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	err = app.models.DB.DeleteStudio(id)
+	if err != nil {
+		app.errorJSON(w, err, studioErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "studio is successfully deleted!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
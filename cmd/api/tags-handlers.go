@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// movie tag payload, a tag is added by its name and removed by its id
+type movieTagPayload struct {
+	MovieID int    `json:"movie_id"`
+	Name    string `json:"name"`
+	TagID   int    `json:"tag_id"`
+}
+
+// review tag suggestion payload
+type reviewTagSuggestionPayload struct {
+	SuggestionID int  `json:"suggestion_id"`
+	Approve      bool `json:"approve"`
+}
+
+// tagErrorStatus returns the response status for an error of the tags
+func tagErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrTagNotFound),
+		errors.Is(err, models.ErrTagNotOnMovie),
+		errors.Is(err, models.ErrTagSuggestionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrTagOnMovie),
+		errors.Is(err, models.ErrTagSuggestionExists):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// validateTagName adds an error to the validator when the tag name is not valid
+func validateTagName(v *validator.Validator, name string) {
+	v.IsLength(models.TagName(name), "name", 2, 50)
+}
+
+// Get the tags with the most movies, the limit query parameter sets how many
+func (app *application) getPopularTags(w http.ResponseWriter, r *http.Request) {
+	limit, err := app.readLimit(r, defaultPopularTags, maxPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	tags, err := app.models.DB.GetPopularTags(limit)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the tags"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, tags, "tags")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get a tag with the tags most often found with it
+func (app *application) getTag(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	tag, err := app.models.DB.GetTagBySlug(params.ByName("slug"), defaultRelatedTags)
+	if err != nil {
+		if !errors.Is(err, models.ErrTagNotFound) {
+			app.logger.Println(err)
+			app.errorJSON(w, errors.New("failed to fetch the tag"), http.StatusInternalServerError)
+			return
+		}
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, tag, "tag")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the movies with a tag
+func (app *application) getMoviesByTag(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	tag, err := app.models.DB.GetTagBySlug(params.ByName("tag"), 0)
+	if err != nil {
+		app.errorJSON(w, models.ErrTagNotFound, http.StatusNotFound)
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	filter := models.MovieFilter{
+		FilterByTag: tag.ID,
+		OrderBy:     r.URL.Query().Get("order_by"),
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+	filter.Certification = cert
+
+	movies, err := app.models.DB.GetAllMoviesByFilter(page, perPage, &filter, userID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, movies)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the tags often found with the tags of a movie that it does not have yet
+func (app *application) getSuggestedTags(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid id parameter"))
+		return
+	}
+
+	limit, err := app.readLimit(r, defaultRelatedTags, maxPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	tags, err := app.models.DB.GetSuggestedTags(id, limit)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the tags"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, tags, "tags")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Suggest a tag for a movie, it shows on the movie once a moderator approves it
+func (app *application) suggestTag(w http.ResponseWriter, r *http.Request) {
+	var payload movieTagPayload
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validateTagName(validator, payload.Name)
+	validator.Check(payload.MovieID > 0, "movie_id", "invalid movie_id!")
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	id, err := app.models.DB.SuggestTag(payload.MovieID, userID, payload.Name)
+	if err != nil {
+		app.errorJSON(w, err, tagErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "thanks, the tag shows on the movie once a moderator approves it!"
+
+	err = app.writeJSON(w, http.StatusCreated, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get a page of the tag suggestions, the pending ones unless the status query parameter says otherwise
+func (app *application) getTagSuggestions(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.TagSuggestionPending
+	}
+
+	if !contains([]string{models.TagSuggestionPending, models.TagSuggestionApproved, models.TagSuggestionRejected}, status) {
+		app.errorJSON(w, errors.New("status should be one of pending, approved or rejected"))
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultTagSuggestionsPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	suggestions, err := app.models.DB.GetTagSuggestions(status, page, perPage)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the tag suggestions"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, suggestions)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Approve or reject a tag suggestion
+func (app *application) reviewTagSuggestion(w http.ResponseWriter, r *http.Request) {
+	var payload reviewTagSuggestionPayload
+
+	// get moderator id from context
+	moderatorID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	if payload.SuggestionID <= 0 {
+		app.errorJSON(w, errors.New("invalid suggestion id"))
+		return
+	}
+
+	err = app.models.DB.ReviewTagSuggestion(payload.SuggestionID, moderatorID, payload.Approve)
+	if err != nil {
+		app.errorJSON(w, err, tagErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.SuggestionID
+	resp.Message = "tag suggestion is rejected!"
+	if payload.Approve {
+		resp.Message = "tag suggestion is approved!"
+	}
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Put a tag on a movie, the tag is created when it does not exist yet
+func (app *application) addMovieTag(w http.ResponseWriter, r *http.Request) {
+	var payload movieTagPayload
+
+	// get admin id from context
+	adminID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validateTagName(validator, payload.Name)
+	validator.Check(payload.MovieID > 0, "movie_id", "invalid movie_id!")
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	tagID, err := app.models.DB.AddMovieTag(payload.MovieID, payload.Name, adminID)
+	if err != nil {
+		app.errorJSON(w, err, tagErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = tagID
+	resp.Message = "tag is added to the movie!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Take a tag off a movie
+func (app *application) removeMovieTag(w http.ResponseWriter, r *http.Request) {
+	var payload movieTagPayload
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	if payload.MovieID <= 0 || payload.TagID <= 0 {
+		app.errorJSON(w, errors.New("invalid movie or tag id"))
+		return
+	}
+
+	err = app.models.DB.RemoveMovieTag(payload.MovieID, payload.TagID)
+	if err != nil {
+		app.errorJSON(w, err, tagErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.TagID
+	resp.Message = "tag is removed from the movie!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Delete a tag from every movie
+func (app *application) deleteTag(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil || id <= 0 {
+		app.errorJSON(w, errors.New("invalid id"))
+		return
+	}
+
+	err = app.models.DB.DeleteTag(id)
+	if err != nil {
+		app.errorJSON(w, err, tagErrorStatus(err))
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "tag is successfully deleted!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// featured movie payload
+type featuredMoviePayload struct {
+	MovieID  int `json:"movie_id"`
+	Position int `json:"position"` // zero puts the movie after the other pinned movies
+}
+
+// Get the trending movies, the window query parameter is one of 24h, 7d or 30d and the limit query parameter
+// sets how many
+func (app *application) getTrendingMovies(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = models.DefaultTrendingWindow
+	}
+
+	duration, ok := models.TrendingWindows[window]
+	if !ok {
+		app.errorJSON(w, errors.New("window should be one of 24h, 7d or 30d"))
+		return
+	}
+
+	limit, err := app.readLimit(r, defaultTrendingMovies, maxPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	// get userID from bareaer token
+	userID, _ := app.parseHeaderToken(r)
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	movies, err := app.models.DB.GetTrendingMovies(duration, limit, cert, userID)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the trending movies"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, movies, "movies")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Get the movies pinned on the featured movies
+func (app *application) getPinnedMovies(w http.ResponseWriter, r *http.Request) {
+	featured, err := app.models.DB.GetPinnedMovies()
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the featured movies"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, featured, "featured")
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Pin a movie on the featured movies, pinning it again moves it to the new position
+func (app *application) pinFeaturedMovie(w http.ResponseWriter, r *http.Request) {
+	var payload featuredMoviePayload
+
+	// get admin id from context
+	adminID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	validator := validator.New()
+	validator.Check(payload.MovieID > 0, "movie_id", "invalid movie_id!")
+	validator.Check(payload.Position >= 0, "position", "position should not be negative")
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.DB.PinFeaturedMovie(payload.MovieID, payload.Position, adminID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = payload.MovieID
+	resp.Message = "movie is pinned on the featured movies!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Take a movie off the pinned featured movies
+func (app *application) unpinFeaturedMovie(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	movieID, err := strconv.Atoi(ps.ByName("movie_id"))
+	if err != nil || movieID <= 0 {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	err = app.models.DB.UnpinFeaturedMovie(movieID)
+	if errors.Is(err, models.ErrNotFeatured) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = movieID
+	resp.Message = "movie is no longer pinned on the featured movies!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/validator"
+)
+
+// Get the preferences of the logged in user
+func (app *application) getPreferences(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	prefs, err := app.models.DB.GetUserPreferences(userID)
+	if err != nil {
+		app.errorJSON(w, errors.New("failed to fetch the preferences"))
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, prefs, "preferences")
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
+
+// Update the preferences of the logged in user, fields that are not sent are left unchanged. An empty
+// max_certification lifts the certification limit
+func (app *application) updatePreferences(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ShowSpoilers         *bool   `json:"show_spoilers"`
+		PrivateProfile       *bool   `json:"private_profile"`
+		CertificationCountry *string `json:"certification_country"`
+		MaxCertification     *string `json:"max_certification"`
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	// read json from the body
+	err := app.readJSON(w, r, &payload)
+	if err != nil {
+		app.badRequest(w, r, errors.New("invalid json"))
+		return
+	}
+
+	prefs, err := app.models.DB.GetUserPreferences(userID)
+	if err != nil {
+		app.errorJSON(w, errors.New("failed to fetch the preferences"))
+		return
+	}
+
+	if payload.ShowSpoilers != nil {
+		prefs.ShowSpoilers = *payload.ShowSpoilers
+	}
+
+	if payload.PrivateProfile != nil {
+		prefs.PrivateProfile = *payload.PrivateProfile
+	}
+
+	if payload.CertificationCountry != nil {
+		prefs.CertificationCountry = strings.ToUpper(strings.TrimSpace(*payload.CertificationCountry))
+	}
+
+	if payload.MaxCertification != nil {
+		prefs.MaxCertification = strings.TrimSpace(*payload.MaxCertification)
+	}
+
+	validator := validator.New()
+	if prefs.MaxCertification != "" {
+		_, known := models.CertificationSystems[prefs.CertificationCountry]
+		validator.Check(known, "certification_country", "certification_country should be one of the supported countries")
+		_, ok := models.CertificationAge(prefs.CertificationCountry, prefs.MaxCertification)
+		validator.Check(!known || ok, "max_certification", "max_certification is not a certification of the country")
+	}
+
+	if !validator.Valid() {
+		err := app.writeJSON(w, http.StatusBadRequest, validator)
+		if err != nil {
+			app.badRequest(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.DB.UpdateUserPreferences(userID, prefs)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK          bool                    `json:"ok"`
+		Preferences *models.UserPreferences `json:"preferences"`
+		Message     string                  `json:"message"`
+	}
+
+	resp.OK = true
+	resp.Preferences = prefs
+	resp.Message = "preferences are successfully updated!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/raihan2bd/filmwise/models"
+)
+
+// Get the watchlist of the logged in user
+func (app *application) getWatchlist(w http.ResponseWriter, r *http.Request) {
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	page, perPage, err := app.readPagination(r, defaultWatchlistPerPage)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	cert, ok := app.certificationLimit(w, userID)
+	if !ok {
+		return
+	}
+
+	watchlist, err := app.models.DB.GetWatchlist(userID, page, perPage, cert)
+	if err != nil {
+		app.logger.Println(err)
+		app.errorJSON(w, errors.New("failed to fetch the watchlist"), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, watchlist)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Add a movie to the watchlist of the logged in user
+func (app *application) addToWatchlist(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	movieID, err := strconv.Atoi(ps.ByName("movie_id"))
+	if err != nil || movieID <= 0 {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	id, err := app.models.DB.AddToWatchlist(userID, movieID)
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = id
+	resp.Message = "Movie is added to the watchlist!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
+
+// Remove a movie from the watchlist of the logged in user
+func (app *application) removeFromWatchlist(w http.ResponseWriter, r *http.Request) {
+	ps := r.Context().Value("params").(httprouter.Params)
+
+	movieID, err := strconv.Atoi(ps.ByName("movie_id"))
+	if err != nil || movieID <= 0 {
+		app.errorJSON(w, errors.New("invalid movie id"))
+		return
+	}
+
+	// get user id from context
+	userID, ok := r.Context().Value(userIDKey("user_id")).(int)
+	if !ok {
+		app.errorJSON(w, errors.New("invalid user type"))
+		return
+	}
+
+	err = app.models.DB.RemoveFromWatchlist(userID, movieID)
+	if errors.Is(err, models.ErrNotInWatchlist) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.errorJSON(w, err)
+		return
+	}
+
+	var resp struct {
+		OK      bool   `json:"ok"`
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	resp.OK = true
+	resp.ID = movieID
+	resp.Message = "Movie is removed from the watchlist!"
+
+	err = app.writeJSON(w, http.StatusOK, resp)
+	if err != nil {
+		app.errorJSON(w, err)
+	}
+}
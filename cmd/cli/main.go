@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"github.com/raihan2bd/filmwise/models"
+	"github.com/raihan2bd/filmwise/recommend"
+)
+
+// command is a maintenance task run against the database
+type command struct {
+	usage string
+	run   func(app *application, args []string) error
+}
+
+type application struct {
+	logger *log.Logger
+	models models.Models
+}
+
+// commands are the maintenance tasks by name
+var commands = map[string]command{
+	"reconcile-stats": {
+		usage: "rebuild movie_stats from the ratings, comments, favorites and reviews",
+		run:   reconcileStats,
+	},
+	"recount-mutes": {
+		usage: "recount the comments of the movies after the shadow mutes that ended",
+		run:   recountMutes,
+	},
+	"refresh-related": {
+		usage: "rebuild the related movies of every movie",
+		run:   refreshRelated,
+	},
+	"train-recommendations": {
+		usage: "train the item-item recommendation model from the ratings",
+		run:   trainRecommendations,
+	},
+}
+
+func main() {
+	// get Environment variables
+	_ = godotenv.Load()
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	// connect with database
+	db, err := openDB(os.Getenv("DATABASE_URI"))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer db.Close()
+
+	app := &application{
+		logger: logger,
+		models: models.NewModels(db, nil),
+	}
+
+	err = cmd.run(app, os.Args[2:])
+	if err != nil {
+		logger.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+// usage prints the available commands
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: cli <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+	}
+}
+
+func openDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// reconcileStats rebuilds movie_stats and reports how many movies drifted
+func reconcileStats(app *application, args []string) error {
+	fixed, err := app.models.DB.ReconcileMovieStats()
+	if err != nil {
+		return err
+	}
+
+	app.logger.Printf("movie stats are reconciled, %d movies were out of date", fixed)
+	return nil
+}
+
+// recountMutes brings the comment counts up to date with the shadow mutes that ended
+func recountMutes(app *application, args []string) error {
+	count, err := app.models.DB.RecountEndedShadowMutes()
+	if err != nil {
+		return err
+	}
+
+	app.logger.Printf("comment counts are recounted after %d ended shadow mutes", count)
+	return nil
+}
+
+// refreshRelated rebuilds related_movies
+func refreshRelated(app *application, args []string) error {
+	count, err := app.models.DB.RefreshRelatedMovies(recommend.DefaultWeights)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Printf("related movies are refreshed, %d pairs are saved", count)
+	return nil
+}
+
+// trainRecommendations rebuilds item_similarities
+func trainRecommendations(app *application, args []string) error {
+	count, err := app.models.DB.TrainItemSimilarities()
+	if err != nil {
+		return err
+	}
+
+	app.logger.Printf("recommendation model is trained, %d similar movie pairs are saved", count)
+	return nil
+}
@@ -0,0 +1,152 @@
+package contentfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Store gives the checks access to what a user posted before
+type Store interface {
+	// CountRecentPosts returns how many comments and reviews the user wrote since the given time
+	CountRecentPosts(userID int, since time.Time) (int, error)
+	// CountDuplicatePosts returns how many comments and reviews of the user since the given time have
+	// the same normalized text, on any movie
+	CountDuplicatePosts(userID int, text string, since time.Time) (int, error)
+}
+
+// Normalize lowercases a text and collapses its white space, duplicates are compared this way
+func Normalize(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// appliesTo tells if a check limited to kinds runs for kind, no kinds means every kind
+func appliesTo(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// WordList flags texts containing any of its words, matched as whole words ignoring case
+type WordList struct {
+	Name   string
+	Action Action
+	Kinds  []string
+	words  *regexp.Regexp
+}
+
+// NewWordList returns a word list check, it matches nothing without words
+func NewWordList(name string, words []string, action Action, kinds ...string) *WordList {
+	var quoted []string
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+
+	list := &WordList{Name: name, Action: action, Kinds: kinds}
+	if len(quoted) > 0 {
+		list.words = regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	}
+
+	return list
+}
+
+// Check flags the text when it contains a listed word
+func (wl *WordList) Check(in *Input) (*Verdict, error) {
+	if wl.words == nil || !appliesTo(wl.Kinds, in.Kind) || !wl.words.MatchString(in.Text) {
+		return nil, nil
+	}
+
+	verdict := &Verdict{Action: wl.Action, Reason: fmt.Sprintf("contains words from the %s list", wl.Name)}
+	if wl.Action == Mask {
+		verdict.Text = wl.words.ReplaceAllStringFunc(in.Text, func(word string) string {
+			return strings.Repeat("*", len([]rune(word)))
+		})
+	}
+
+	return verdict, nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+`)
+
+// LinkLimit flags texts with more links than Max
+type LinkLimit struct {
+	Max    int
+	Action Action
+	Kinds  []string
+}
+
+// Check flags the text when it has too many links
+func (ll *LinkLimit) Check(in *Input) (*Verdict, error) {
+	if !appliesTo(ll.Kinds, in.Kind) {
+		return nil, nil
+	}
+
+	links := len(linkPattern.FindAllStringIndex(in.Text, -1))
+	if links <= ll.Max {
+		return nil, nil
+	}
+
+	return &Verdict{Action: ll.Action, Reason: fmt.Sprintf("has %d links, at most %d are allowed", links, ll.Max)}, nil
+}
+
+// Duplicates flags a new post whose text the user already posted within Window, on any movie
+type Duplicates struct {
+	Store  Store
+	Window time.Duration
+	Action Action
+	Kinds  []string
+}
+
+// Check flags the text when the user posted it before
+func (d *Duplicates) Check(in *Input) (*Verdict, error) {
+	if in.UserID <= 0 || in.ID > 0 || !appliesTo(d.Kinds, in.Kind) {
+		return nil, nil
+	}
+
+	count, err := d.Store.CountDuplicatePosts(in.UserID, Normalize(in.Text), time.Now().Add(-d.Window))
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+
+	return &Verdict{Action: d.Action, Reason: "the same text is already posted"}, nil
+}
+
+// RateLimit flags a new post once the user wrote Max posts within Window
+type RateLimit struct {
+	Store  Store
+	Max    int
+	Window time.Duration
+	Action Action
+	Kinds  []string
+}
+
+// Check flags the text when the user posts too often
+func (rl *RateLimit) Check(in *Input) (*Verdict, error) {
+	if in.UserID <= 0 || in.ID > 0 || !appliesTo(rl.Kinds, in.Kind) {
+		return nil, nil
+	}
+
+	count, err := rl.Store.CountRecentPosts(in.UserID, time.Now().Add(-rl.Window))
+	if err != nil {
+		return nil, err
+	}
+
+	if count < rl.Max {
+		return nil, nil
+	}
+
+	return &Verdict{Action: rl.Action, Reason: fmt.Sprintf("posting too fast, at most %d posts in %s", rl.Max, rl.Window), TooFast: true}, nil
+}
@@ -0,0 +1,332 @@
+package contentfilter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeStore answers with fixed counts and keeps what it was asked
+type fakeStore struct {
+	recent     int
+	duplicates int
+	err        error
+	userID     int
+	text       string
+	since      time.Time
+}
+
+func (s *fakeStore) CountRecentPosts(userID int, since time.Time) (int, error) {
+	s.userID, s.since = userID, since
+	return s.recent, s.err
+}
+
+func (s *fakeStore) CountDuplicatePosts(userID int, text string, since time.Time) (int, error) {
+	s.userID, s.text, s.since = userID, text, since
+	return s.duplicates, s.err
+}
+
+// action returns the action of a verdict, allow for no verdict
+func action(v *Verdict) Action {
+	if v == nil {
+		return Allow
+	}
+	return v.Action
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Great Movie", want: "great movie"},
+		{text: "  great \t movie\n\nreally ", want: "great movie really"},
+		{text: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.text); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWordList(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     *WordList
+		kind     string
+		text     string
+		want     Action
+		wantText string
+	}{
+		{
+			name:     "masks whole words ignoring case",
+			list:     NewWordList("profanity", []string{"darn", "heck"}, Mask),
+			text:     "Darn, what the HECK",
+			want:     Mask,
+			wantText: "****, what the ****",
+		},
+		{
+			name: "part of a word is left alone",
+			list: NewWordList("profanity", []string{"darn"}, Mask),
+			text: "darned",
+			want: Allow,
+		},
+		{
+			name:     "one asterisk per letter",
+			list:     NewWordList("profanity", []string{"dämn"}, Mask),
+			text:     "dämn it",
+			want:     Mask,
+			wantText: "**** it",
+		},
+		{
+			name:     "words are quoted",
+			list:     NewWordList("links", []string{"a.b"}, Mask),
+			text:     "a.b and axb",
+			want:     Mask,
+			wantText: "*** and axb",
+		},
+		{
+			name: "no text for a stronger action",
+			list: NewWordList("slurs", []string{"darn"}, Reject),
+			text: "darn",
+			want: Reject,
+		},
+		{
+			name: "no words",
+			list: NewWordList("empty", []string{" ", ""}, Reject),
+			text: "anything",
+			want: Allow,
+		},
+		{
+			name: "other kinds are left alone",
+			list: NewWordList("profanity", []string{"darn"}, Reject, KindDisplayName),
+			kind: KindComment,
+			text: "darn",
+			want: Allow,
+		},
+		{
+			name: "listed kinds are checked",
+			list: NewWordList("profanity", []string{"darn"}, Reject, KindDisplayName),
+			kind: KindDisplayName,
+			text: "darn",
+			want: Reject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := tt.list.Check(&Input{Kind: tt.kind, Text: tt.text})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+
+			if got := action(verdict); got != tt.want {
+				t.Fatalf("Check() = %q, want %q", got, tt.want)
+			}
+			if verdict != nil && verdict.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", verdict.Text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestLinkLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit LinkLimit
+		kind  string
+		text  string
+		want  Action
+	}{
+		{
+			name:  "at the limit",
+			limit: LinkLimit{Max: 2, Action: Queue},
+			text:  "see https://a.example and www.b.example",
+			want:  Allow,
+		},
+		{
+			name:  "over the limit",
+			limit: LinkLimit{Max: 2, Action: Queue},
+			text:  "http://a.example HTTPS://b.example www.c.example",
+			want:  Queue,
+		},
+		{
+			name:  "no links allowed",
+			limit: LinkLimit{Max: 0, Action: Reject},
+			text:  "visit www.a.example",
+			want:  Reject,
+		},
+		{
+			name:  "a bare domain is not a link",
+			limit: LinkLimit{Max: 0, Action: Reject},
+			text:  "it is on a.example",
+			want:  Allow,
+		},
+		{
+			name:  "other kinds are left alone",
+			limit: LinkLimit{Max: 0, Action: Reject, Kinds: []string{KindReview}},
+			kind:  KindComment,
+			text:  "visit www.a.example",
+			want:  Allow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := tt.limit.Check(&Input{Kind: tt.kind, Text: tt.text})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+
+			if got := action(verdict); got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        Input
+		count     int
+		want      Action
+		wantAsked bool
+	}{
+		{
+			name:      "posted before",
+			in:        Input{Kind: KindComment, UserID: 7, Text: "  Great   MOVIE "},
+			count:     1,
+			want:      Reject,
+			wantAsked: true,
+		},
+		{
+			name:      "not posted before",
+			in:        Input{Kind: KindComment, UserID: 7, Text: "great movie"},
+			want:      Allow,
+			wantAsked: true,
+		},
+		{
+			name:  "an edit is not checked",
+			in:    Input{Kind: KindComment, UserID: 7, ID: 3, Text: "great movie"},
+			count: 1,
+			want:  Allow,
+		},
+		{
+			name:  "no user",
+			in:    Input{Kind: KindComment, Text: "great movie"},
+			count: 1,
+			want:  Allow,
+		},
+		{
+			name:  "other kinds are left alone",
+			in:    Input{Kind: KindDisplayName, UserID: 7, Text: "great movie"},
+			count: 1,
+			want:  Allow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{duplicates: tt.count}
+			check := &Duplicates{Store: store, Window: time.Hour, Action: Reject, Kinds: []string{KindComment, KindReview}}
+
+			before := time.Now()
+			verdict, err := check.Check(&tt.in)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+
+			if got := action(verdict); got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+
+			if asked := store.userID != 0; asked != tt.wantAsked {
+				t.Fatalf("store asked = %v, want %v", asked, tt.wantAsked)
+			}
+			if !tt.wantAsked {
+				return
+			}
+
+			if store.userID != tt.in.UserID || store.text != "great movie" {
+				t.Errorf("store asked for user %d and %q, want user %d and %q", store.userID, store.text, tt.in.UserID, "great movie")
+			}
+			// the window ends when the check runs
+			if from, to := before.Add(-time.Hour), time.Now().Add(-time.Hour); store.since.Before(from) || store.since.After(to) {
+				t.Errorf("store asked since %v, want between %v and %v", store.since, from, to)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    Input
+		count int
+		want  Action
+	}{
+		{
+			name:  "under the limit",
+			in:    Input{Kind: KindReview, UserID: 7, Text: "a review"},
+			count: 4,
+			want:  Allow,
+		},
+		{
+			name:  "at the limit",
+			in:    Input{Kind: KindReview, UserID: 7, Text: "a review"},
+			count: 5,
+			want:  Reject,
+		},
+		{
+			name:  "an edit is not limited",
+			in:    Input{Kind: KindReview, UserID: 7, ID: 3, Text: "a review"},
+			count: 50,
+			want:  Allow,
+		},
+		{
+			name:  "no user",
+			in:    Input{Kind: KindReview, Text: "a review"},
+			count: 50,
+			want:  Allow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{recent: tt.count}
+			check := &RateLimit{Store: store, Max: 5, Window: 10 * time.Minute, Action: Reject}
+
+			verdict, err := check.Check(&tt.in)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+
+			if got := action(verdict); got != tt.want {
+				t.Fatalf("Check() = %q, want %q", got, tt.want)
+			}
+			if verdict != nil && !verdict.TooFast {
+				t.Errorf("TooFast = false, want true")
+			}
+		})
+	}
+}
+
+func TestStoreError(t *testing.T) {
+	want := errors.New("store is down")
+	store := &fakeStore{err: want}
+	in := &Input{Kind: KindComment, UserID: 7, Text: "great movie"}
+
+	checks := map[string]Check{
+		"duplicates": &Duplicates{Store: store, Window: time.Hour, Action: Reject},
+		"rate limit": &RateLimit{Store: store, Max: 5, Window: time.Minute, Action: Reject},
+	}
+
+	for name, check := range checks {
+		if _, err := check.Check(in); !errors.Is(err, want) {
+			t.Errorf("%s: Check() error = %v, want %v", name, err, want)
+		}
+	}
+}
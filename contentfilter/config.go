@@ -0,0 +1,126 @@
+package contentfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config describes the filter pipeline, it is read from a json file like
+//
+//	{
+//		"word_lists": [{"name": "profanity", "words": ["..."], "action": "mask"}],
+//		"links": {"max": 2, "action": "queue"},
+//		"duplicates": {"window_hours": 24, "action": "reject", "kinds": ["comment", "review"]},
+//		"rate_limit": {"max_posts": 5, "window_minutes": 10, "action": "reject"}
+//	}
+//
+// A check left out of the file is turned off.
+type Config struct {
+	WordLists []struct {
+		Name   string   `json:"name"`
+		Words  []string `json:"words"`
+		Action Action   `json:"action"`
+		Kinds  []string `json:"kinds"`
+	} `json:"word_lists"`
+	Links *struct {
+		Max    int      `json:"max"`
+		Action Action   `json:"action"`
+		Kinds  []string `json:"kinds"`
+	} `json:"links"`
+	Duplicates *struct {
+		WindowHours int      `json:"window_hours"`
+		Action      Action   `json:"action"`
+		Kinds       []string `json:"kinds"`
+	} `json:"duplicates"`
+	RateLimit *struct {
+		MaxPosts      int      `json:"max_posts"`
+		WindowMinutes int      `json:"window_minutes"`
+		Action        Action   `json:"action"`
+		Kinds         []string `json:"kinds"`
+	} `json:"rate_limit"`
+}
+
+// DefaultConfig is used when no config file is given, it only limits links, duplicates and the posting rate
+func DefaultConfig() *Config {
+	var cfg Config
+	_ = json.Unmarshal([]byte(`{
+		"links": {"max": 2, "action": "queue"},
+		"duplicates": {"window_hours": 24, "action": "reject"},
+		"rate_limit": {"max_posts": 5, "window_minutes": 10, "action": "reject"}
+	}`), &cfg)
+	return &cfg
+}
+
+// LoadConfig reads the config from a json file, an empty path gives the default config
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		return DefaultConfig(), nil
+	}
+
+	js, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	err = json.Unmarshal(js, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content filter config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// validAction checks an action read from the config
+func validAction(action Action, check string) error {
+	if _, ok := severity[action]; !ok || action == Allow {
+		return fmt.Errorf("invalid content filter config: %s action should be one of mask, queue or reject", check)
+	}
+	return nil
+}
+
+// FromConfig builds the pipeline described by cfg: word lists, links, duplicates and then the rate limit
+func FromConfig(cfg *Config, store Store) (*Filter, error) {
+	var checks []Check
+
+	for _, list := range cfg.WordLists {
+		if err := validAction(list.Action, "word list "+list.Name); err != nil {
+			return nil, err
+		}
+		checks = append(checks, NewWordList(list.Name, list.Words, list.Action, list.Kinds...))
+	}
+
+	if l := cfg.Links; l != nil {
+		if err := validAction(l.Action, "links"); err != nil {
+			return nil, err
+		}
+		if l.Action == Mask {
+			return nil, fmt.Errorf("invalid content filter config: links can't be masked")
+		}
+		checks = append(checks, &LinkLimit{Max: l.Max, Action: l.Action, Kinds: l.Kinds})
+	}
+
+	if d := cfg.Duplicates; d != nil {
+		if err := validAction(d.Action, "duplicates"); err != nil {
+			return nil, err
+		}
+		if d.Action == Mask || d.WindowHours <= 0 {
+			return nil, fmt.Errorf("invalid content filter config: duplicates need a window and can't be masked")
+		}
+		checks = append(checks, &Duplicates{Store: store, Window: time.Duration(d.WindowHours) * time.Hour, Action: d.Action, Kinds: d.Kinds})
+	}
+
+	if rl := cfg.RateLimit; rl != nil {
+		if err := validAction(rl.Action, "rate limit"); err != nil {
+			return nil, err
+		}
+		if rl.Action == Mask || rl.MaxPosts <= 0 || rl.WindowMinutes <= 0 {
+			return nil, fmt.Errorf("invalid content filter config: rate limit needs max_posts and a window and can't be masked")
+		}
+		checks = append(checks, &RateLimit{Store: store, Max: rl.MaxPosts, Window: time.Duration(rl.WindowMinutes) * time.Minute, Action: rl.Action, Kinds: rl.Kinds})
+	}
+
+	return New(checks...), nil
+}
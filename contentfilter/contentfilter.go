@@ -0,0 +1,106 @@
+package contentfilter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Action is what happens to a text flagged by a check, a later action in the list is stronger
+type Action string
+
+const (
+	Allow  Action = "allow"
+	Mask   Action = "mask"   // the flagged words are replaced with asterisks
+	Queue  Action = "queue"  // the text is saved hidden and reported to the moderators
+	Reject Action = "reject" // the text is not saved
+)
+
+// severity orders the actions from the weakest to the strongest
+var severity = map[Action]int{Allow: 0, Mask: 1, Queue: 2, Reject: 3}
+
+// kinds of user text
+const (
+	KindComment     = "comment"
+	KindReview      = "review"
+	KindDisplayName = "display_name"
+	KindList        = "list"
+)
+
+// Input is a user text to check
+type Input struct {
+	Kind   string
+	UserID int
+	ID     int // the comment or review being edited, zero for a new one
+	Text   string
+}
+
+// Verdict is the decision of one check
+type Verdict struct {
+	Action  Action
+	Reason  string
+	Text    string // the masked text when Action is Mask
+	TooFast bool   // the user posts too often, whatever the text
+}
+
+// Check is a step of the filter pipeline
+type Check interface {
+	Check(in *Input) (*Verdict, error)
+}
+
+// Result is the decision of the whole pipeline
+type Result struct {
+	Action  Action
+	Text    string
+	Reasons []string
+	TooFast bool // the text is rejected because the user posts too often
+}
+
+// Error tells why a text was rejected
+func (res *Result) Error() error {
+	return fmt.Errorf("rejected by the content filter: %s", strings.Join(res.Reasons, ", "))
+}
+
+// Filter runs user text through its checks in order
+type Filter struct {
+	checks []Check
+}
+
+// New returns a filter running the checks in the given order
+func New(checks ...Check) *Filter {
+	return &Filter{checks: checks}
+}
+
+// Run checks a text. A masked text is passed on to the next checks and the strongest action wins,
+// the pipeline stops at the first rejection
+func (f *Filter) Run(in Input) (*Result, error) {
+	res := &Result{Action: Allow, Text: in.Text}
+
+	for _, check := range f.checks {
+		in.Text = res.Text
+
+		verdict, err := check.Check(&in)
+		if err != nil {
+			return nil, err
+		}
+
+		if verdict == nil || verdict.Action == Allow {
+			continue
+		}
+
+		res.Reasons = append(res.Reasons, verdict.Reason)
+		if verdict.Action == Mask {
+			res.Text = verdict.Text
+		}
+
+		if severity[verdict.Action] > severity[res.Action] {
+			res.Action = verdict.Action
+		}
+
+		if res.Action == Reject {
+			res.TooFast = verdict.TooFast
+			break
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,143 @@
+package contentfilter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fixed is a check that always gives the same verdict and keeps the text it was given
+type fixed struct {
+	verdict *Verdict
+	err     error
+	seen    *[]string
+}
+
+func (f fixed) Check(in *Input) (*Verdict, error) {
+	if f.seen != nil {
+		*f.seen = append(*f.seen, in.Text)
+	}
+	return f.verdict, f.err
+}
+
+func TestFilterRun(t *testing.T) {
+	mask := NewWordList("profanity", []string{"darn"}, Mask)
+
+	tests := []struct {
+		name        string
+		checks      []Check
+		text        string
+		want        Action
+		wantText    string
+		wantReasons []string
+		wantTooFast bool
+		stopped     bool
+	}{
+		{
+			name:     "no checks",
+			text:     "fine text",
+			want:     Allow,
+			wantText: "fine text",
+		},
+		{
+			name:     "allow and nil verdicts leave no reason",
+			checks:   []Check{fixed{verdict: &Verdict{Action: Allow, Reason: "ok"}}, fixed{}},
+			text:     "fine text",
+			want:     Allow,
+			wantText: "fine text",
+		},
+		{
+			name:        "the strongest action wins",
+			checks:      []Check{fixed{verdict: &Verdict{Action: Queue, Reason: "links"}}, mask},
+			text:        "darn it",
+			want:        Queue,
+			wantText:    "**** it",
+			wantReasons: []string{"links", "contains words from the profanity list"},
+		},
+		{
+			name:        "a masked text is passed on",
+			checks:      []Check{mask, NewWordList("again", []string{"darn"}, Reject)},
+			text:        "darn it",
+			want:        Mask,
+			wantText:    "**** it",
+			wantReasons: []string{"contains words from the profanity list"},
+		},
+		{
+			name: "stops at the first rejection",
+			checks: []Check{
+				fixed{verdict: &Verdict{Action: Reject, Reason: "duplicate"}},
+				fixed{verdict: &Verdict{Action: Reject, Reason: "too fast", TooFast: true}},
+			},
+			text:        "fine text",
+			want:        Reject,
+			wantText:    "fine text",
+			wantReasons: []string{"duplicate"},
+			stopped:     true,
+		},
+		{
+			name: "too fast comes from the rejecting check",
+			checks: []Check{
+				fixed{verdict: &Verdict{Action: Queue, Reason: "links", TooFast: true}},
+				fixed{verdict: &Verdict{Action: Reject, Reason: "too fast", TooFast: true}},
+			},
+			text:        "fine text",
+			want:        Reject,
+			wantText:    "fine text",
+			wantReasons: []string{"links", "too fast"},
+			wantTooFast: true,
+			stopped:     true,
+		},
+		{
+			name:        "too fast is only set on a rejection",
+			checks:      []Check{fixed{verdict: &Verdict{Action: Queue, Reason: "too fast", TooFast: true}}},
+			text:        "fine text",
+			want:        Queue,
+			wantText:    "fine text",
+			wantReasons: []string{"too fast"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []string
+			checks := append([]Check{}, tt.checks...)
+			checks = append(checks, fixed{seen: &seen})
+
+			res, err := New(checks...).Run(Input{Kind: KindComment, Text: tt.text})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if res.Action != tt.want {
+				t.Errorf("Action = %q, want %q", res.Action, tt.want)
+			}
+			if res.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", res.Text, tt.wantText)
+			}
+			if !reflect.DeepEqual(res.Reasons, tt.wantReasons) {
+				t.Errorf("Reasons = %q, want %q", res.Reasons, tt.wantReasons)
+			}
+			if res.TooFast != tt.wantTooFast {
+				t.Errorf("TooFast = %v, want %v", res.TooFast, tt.wantTooFast)
+			}
+
+			// a check added after the others sees the final text unless the pipeline stopped
+			var wantSeen []string
+			if !tt.stopped {
+				wantSeen = []string{tt.wantText}
+			}
+			if !reflect.DeepEqual(seen, wantSeen) {
+				t.Errorf("the last check saw %q, want %q", seen, wantSeen)
+			}
+		})
+	}
+}
+
+func TestFilterRunError(t *testing.T) {
+	want := errors.New("store is down")
+
+	_, err := New(fixed{err: want}).Run(Input{Kind: KindComment, Text: "fine text"})
+	if !errors.Is(err, want) {
+		t.Errorf("Run() error = %v, want %v", err, want)
+	}
+}
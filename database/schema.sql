@@ -130,4 +130,611 @@ CREATE TABLE images (
 );
 
 -- Alter table movies add column image
-ALTER TABLE movies ADD COLUMN image varchar(255);
\ No newline at end of file
+ALTER TABLE movies ADD COLUMN image varchar(255);
+
+-- Alter table comments add parent_id and depth for threaded replies
+ALTER TABLE comments ADD COLUMN parent_id integer;
+ALTER TABLE comments ADD CONSTRAINT fk_parent_id FOREIGN KEY (parent_id) REFERENCES comments (id) ON DELETE CASCADE;
+ALTER TABLE comments ADD COLUMN depth integer not null default 0;
+
+-- Alter table comments add is_deleted to keep a placeholder for removed comments with replies
+ALTER TABLE comments ADD COLUMN is_deleted boolean not null default false;
+
+CREATE INDEX comments_parent_id_idx ON comments (parent_id);
+
+-- Create comment_reactions table inside the database, a user can leave one reaction per comment
+CREATE TABLE comment_reactions (
+    id serial not null primary key,
+    comment_id integer not null,
+    user_id integer not null,
+    reaction varchar(20) not null,
+    created_at timestamp,
+    updated_at timestamp,
+    CONSTRAINT fk_comment_id
+      FOREIGN KEY(comment_id)
+      REFERENCES comments(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT comment_reactions_comment_id_user_id_key UNIQUE (comment_id, user_id)
+);
+
+-- Alter table comments add soft deletion details so a removed comment can be restored
+ALTER TABLE comments ADD COLUMN deleted_at timestamp;
+ALTER TABLE comments ADD COLUMN deleted_by integer;
+ALTER TABLE comments ADD CONSTRAINT fk_deleted_by FOREIGN KEY (deleted_by) REFERENCES users (id) ON DELETE SET NULL;
+ALTER TABLE comments ADD COLUMN deletion_reason text;
+
+-- Alter table comments add edited_at
+ALTER TABLE comments ADD COLUMN edited_at timestamp;
+
+-- Create comment_revisions table inside the database, it keeps the text of a comment before each edit
+CREATE TABLE comment_revisions (
+    id serial not null primary key,
+    comment_id integer not null,
+    comment text not null,
+    edited_by integer,
+    created_at timestamp,
+    CONSTRAINT fk_comment_id
+      FOREIGN KEY(comment_id)
+      REFERENCES comments(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_edited_by
+      FOREIGN KEY(edited_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+
+-- Create reviews table inside the database, a user can write one review per movie they rated
+CREATE TABLE reviews (
+    id serial not null primary key,
+    movie_id integer not null,
+    user_id integer not null,
+    title varchar(150) not null,
+    body text not null,
+    is_spoiler boolean not null default false,
+    created_at timestamp,
+    updated_at timestamp,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT reviews_movie_id_user_id_key UNIQUE (movie_id, user_id)
+);
+
+-- Alter table comments and reviews add spoiler segments parsed from the ||spoiler|| markup
+ALTER TABLE comments ADD COLUMN comment_segments jsonb;
+ALTER TABLE reviews ADD COLUMN body_segments jsonb;
+
+-- Alter table users add show_spoilers preference
+ALTER TABLE users ADD COLUMN show_spoilers boolean not null default false;
+
+-- Alter table comments and reviews add is_hidden, hidden content is kept out of public listings until a moderator decides
+ALTER TABLE comments ADD COLUMN is_hidden boolean not null default false;
+ALTER TABLE reviews ADD COLUMN is_hidden boolean not null default false;
+
+-- Create reports table inside the database, a user can report a comment, a review or a profile once
+CREATE TABLE reports (
+    id serial not null primary key,
+    target_type varchar(20) not null,
+    target_id integer not null,
+    target_user_id integer,
+    reporter_id integer not null,
+    reason varchar(30) not null,
+    note text,
+    status varchar(20) not null default 'open',
+    claimed_by integer,
+    claimed_at timestamp,
+    resolved_by integer,
+    resolved_at timestamp,
+    resolution varchar(20),
+    created_at timestamp,
+    updated_at timestamp,
+    CONSTRAINT fk_target_user_id
+      FOREIGN KEY(target_user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_reporter_id
+      FOREIGN KEY(reporter_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_claimed_by
+      FOREIGN KEY(claimed_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL,
+    CONSTRAINT fk_resolved_by
+      FOREIGN KEY(resolved_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL,
+    CONSTRAINT reports_target_type_target_id_reporter_id_key UNIQUE (target_type, target_id, reporter_id)
+);
+CREATE INDEX reports_status_idx ON reports (status);
+
+-- Create moderation_actions table inside the database, it is the audit trail of every moderation decision
+CREATE TABLE moderation_actions (
+    id serial not null primary key,
+    report_id integer,
+    moderator_id integer,
+    target_type varchar(20) not null,
+    target_id integer not null,
+    target_user_id integer,
+    action varchar(20) not null,
+    note text,
+    created_at timestamp,
+    CONSTRAINT fk_report_id
+      FOREIGN KEY(report_id)
+      REFERENCES reports(id)
+      ON DELETE SET NULL,
+    CONSTRAINT fk_moderator_id
+      FOREIGN KEY(moderator_id)
+      REFERENCES users(id)
+      ON DELETE SET NULL,
+    CONSTRAINT fk_target_user_id
+      FOREIGN KEY(target_user_id)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+
+-- Create user_sanctions table inside the database, a sanction without ends_at is permanent
+CREATE TABLE user_sanctions (
+    id serial not null primary key,
+    user_id integer not null,
+    kind varchar(20) not null,
+    reason text not null,
+    issued_by integer,
+    ends_at timestamp,
+    revoked_at timestamp,
+    revoked_by integer,
+    revoke_reason text,
+    created_at timestamp,
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_issued_by
+      FOREIGN KEY(issued_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL,
+    CONSTRAINT fk_revoked_by
+      FOREIGN KEY(revoked_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+CREATE INDEX user_sanctions_user_id_idx ON user_sanctions (user_id);
+
+-- Alter table reports make reporter_id optional, reports made by the content filter have no reporter
+ALTER TABLE reports ALTER COLUMN reporter_id DROP NOT NULL;
+
+-- Create movie_stats table inside the database, it is kept up to date on every write and can be rebuilt with
+-- go run ./cmd/cli reconcile-stats
+CREATE TABLE movie_stats (
+    movie_id integer not null primary key,
+    rating_sum double precision not null default 0,
+    rating_count integer not null default 0,
+    comment_count integer not null default 0,
+    favorite_count integer not null default 0,
+    review_count integer not null default 0,
+    updated_at timestamp,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+INSERT INTO movie_stats (movie_id, rating_sum, rating_count, comment_count, favorite_count, review_count, updated_at)
+SELECT
+    m.id,
+    COALESCE((SELECT SUM(r.rating::float8) FROM ratings r WHERE r.movie_id = m.id), 0),
+    (SELECT COUNT(r.id) FROM ratings r WHERE r.movie_id = m.id),
+    (SELECT COUNT(c.id) FROM comments c WHERE c.movie_id = m.id AND c.is_deleted = false AND c.is_hidden = false),
+    (SELECT COUNT(f.id) FROM favorites f WHERE f.movie_id = m.id),
+    (SELECT COUNT(rv.id) FROM reviews rv WHERE rv.movie_id = m.id AND rv.is_hidden = false),
+    now()
+FROM movies m;
+
+-- Create user_lists table inside the database, lists are shared by their slug and can be public, unlisted or private
+CREATE TABLE user_lists (
+    id serial not null primary key,
+    user_id integer not null,
+    name varchar(100) not null,
+    slug varchar(140) not null unique,
+    description text not null default '',
+    visibility varchar(10) not null default 'private',
+    view_count integer not null default 0,
+    created_at timestamp,
+    updated_at timestamp,
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX user_lists_user_id_idx ON user_lists (user_id);
+CREATE INDEX user_lists_public_idx ON user_lists (view_count DESC) WHERE visibility = 'public';
+
+-- Create user_list_items table inside the database, items are ordered by position
+CREATE TABLE user_list_items (
+    id serial not null primary key,
+    list_id integer not null,
+    movie_id integer not null,
+    position integer not null,
+    created_at timestamp,
+    UNIQUE (list_id, movie_id),
+    CONSTRAINT fk_list_id
+      FOREIGN KEY(list_id)
+      REFERENCES user_lists(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX user_list_items_list_id_idx ON user_list_items (list_id, position);
+
+-- Create watchlist table inside the database, the movies a user wants to watch
+CREATE TABLE watchlist (
+    id serial not null primary key,
+    user_id integer not null,
+    movie_id integer not null,
+    created_at timestamp,
+    UNIQUE (user_id, movie_id),
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+
+-- Create diary_entries table inside the database, one row for each viewing of a movie logged by a user
+CREATE TABLE diary_entries (
+    id serial not null primary key,
+    user_id integer not null,
+    movie_id integer not null,
+    watched_on date not null,
+    rating real,
+    is_rewatch boolean not null default false,
+    created_at timestamp,
+    updated_at timestamp,
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX diary_entries_user_id_idx ON diary_entries (user_id, watched_on DESC);
+
+-- Alter table favorites allow a movie only once in the favorites of a user, duplicates are removed first and
+-- the favorite counts of movie_stats are rebuilt
+DELETE FROM favorites f USING favorites d
+WHERE f.user_id = d.user_id AND f.movie_id = d.movie_id AND f.id > d.id;
+ALTER TABLE favorites ADD CONSTRAINT favorites_user_id_movie_id_key UNIQUE (user_id, movie_id);
+UPDATE movie_stats ms SET favorite_count = (SELECT COUNT(f.id) FROM favorites f WHERE f.movie_id = ms.movie_id);
+
+-- Alter table users add is_private, the activity of a private profile is only shown to the followers it accepted
+ALTER TABLE users ADD COLUMN is_private boolean not null default false;
+
+-- Create follows table inside the database, following a private profile stays pending until it is accepted
+CREATE TABLE follows (
+    follower_id integer not null,
+    followee_id integer not null,
+    status varchar(10) not null default 'accepted',
+    created_at timestamp,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id),
+    CONSTRAINT fk_follower_id
+      FOREIGN KEY(follower_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_followee_id
+      FOREIGN KEY(followee_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX follows_followee_id_idx ON follows (followee_id, status);
+
+-- Create activities table inside the database, the feed reads the activities of the followees of a user
+CREATE TABLE activities (
+    id bigserial not null primary key,
+    user_id integer not null,
+    verb varchar(20) not null,
+    movie_id integer,
+    target_id integer not null,
+    created_at timestamp,
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX activities_user_id_idx ON activities (user_id, created_at DESC);
+CREATE INDEX activities_target_idx ON activities (user_id, verb, target_id);
+
+-- Create related_movies table inside the database, it holds the "more like this" movies of every movie and is
+-- rebuilt with go run ./cmd/cli refresh-related, or by the api when RELATED_REFRESH_INTERVAL is set
+CREATE TABLE related_movies (
+    movie_id integer not null,
+    related_id integer not null,
+    score double precision not null,
+    computed_at timestamp,
+    PRIMARY KEY (movie_id, related_id),
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_related_id
+      FOREIGN KEY(related_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX related_movies_score_idx ON related_movies (movie_id, score DESC);
+
+-- Create item_similarities table inside the database, the item-item model behind the recommendations of a user.
+-- It is trained from the ratings with go run ./cmd/cli train-recommendations
+CREATE TABLE item_similarities (
+    movie_id integer not null,
+    similar_id integer not null,
+    similarity double precision not null,
+    trained_at timestamp,
+    PRIMARY KEY (movie_id, similar_id),
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_similar_id
+      FOREIGN KEY(similar_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+
+-- Create featured_movies table inside the database, the titles pinned by the admins on top of the featured
+-- movies, the rest of the featured movies are the trending ones
+CREATE TABLE featured_movies (
+    movie_id integer not null primary key,
+    position integer not null,
+    added_by integer,
+    created_at timestamp,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_added_by
+      FOREIGN KEY(added_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+
+-- Indexes for the trending movies, they sum the recent ratings, favorites and comments
+CREATE INDEX ratings_updated_at_idx ON ratings (updated_at);
+CREATE INDEX favorites_created_at_idx ON favorites (created_at);
+CREATE INDEX comments_created_at_idx ON comments (created_at);
+
+-- Create collections table inside the database, the themed collections of movies built by the admins. A
+-- collection is a draft while publish_at is null and is shown from publish_at on
+CREATE TABLE collections (
+    id serial not null primary key,
+    title varchar(255) not null,
+    slug varchar(120) not null unique,
+    description text not null default '',
+    cover_image varchar(255),
+    publish_at timestamp,
+    created_by integer,
+    created_at timestamp,
+    updated_at timestamp,
+    CONSTRAINT fk_created_by
+      FOREIGN KEY(created_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+CREATE INDEX collections_publish_at_idx ON collections (publish_at DESC);
+
+-- Create collection_items table inside the database, the ordered movies of a collection
+CREATE TABLE collection_items (
+    id serial not null primary key,
+    collection_id integer not null,
+    movie_id integer not null,
+    position integer not null,
+    UNIQUE (collection_id, movie_id),
+    CONSTRAINT fk_collection_id
+      FOREIGN KEY(collection_id)
+      REFERENCES collections(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX collection_items_position_idx ON collection_items (collection_id, position);
+
+-- Merge the genres with the same name, case aside, into the oldest one before names are made unique
+UPDATE movies_genres mg SET genre_id = d.keep_id
+FROM (SELECT id, MIN(id) OVER (PARTITION BY lower(genre_name)) AS keep_id FROM genres) d
+WHERE mg.genre_id = d.id AND d.id <> d.keep_id;
+DELETE FROM movies_genres a USING movies_genres b
+WHERE a.movie_id = b.movie_id AND a.genre_id = b.genre_id AND a.id > b.id;
+DELETE FROM genres g USING genres k
+WHERE lower(g.genre_name) = lower(k.genre_name) AND g.id > k.id;
+CREATE UNIQUE INDEX genres_name_idx ON genres (lower(genre_name));
+ALTER TABLE movies_genres ADD CONSTRAINT movies_genres_movie_genre_key UNIQUE (movie_id, genre_id);
+
+-- Alter table genres add slug, description, parent_id for sub genres and is_default for the genre of the
+-- movies added without one
+ALTER TABLE genres ADD COLUMN slug varchar(120);
+ALTER TABLE genres ADD COLUMN description text not null default '';
+ALTER TABLE genres ADD COLUMN parent_id integer;
+ALTER TABLE genres ADD CONSTRAINT fk_parent_id FOREIGN KEY (parent_id) REFERENCES genres (id) ON DELETE SET NULL;
+ALTER TABLE genres ADD COLUMN is_default boolean not null default false;
+UPDATE genres SET slug = trim(both '-' from regexp_replace(lower(genre_name), '[^a-z0-9]+', '-', 'g'));
+UPDATE genres SET slug = 'genre-' || id WHERE slug = '';
+UPDATE genres g SET slug = g.slug || '-' || g.id FROM genres k WHERE g.slug = k.slug AND g.id > k.id;
+ALTER TABLE genres ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE genres ADD CONSTRAINT genres_slug_key UNIQUE (slug);
+CREATE INDEX genres_parent_id_idx ON genres (parent_id);
+CREATE UNIQUE INDEX genres_default_idx ON genres (is_default) WHERE is_default;
+-- the movies added without a genre used to get the genre with id 10, named Unknown
+UPDATE genres SET is_default = true WHERE id = (SELECT id FROM genres WHERE lower(genre_name) = 'unknown' ORDER BY id LIMIT 1);
+
+-- Create tags table inside the database, the free form keywords of the movies
+CREATE TABLE tags (
+    id serial not null primary key,
+    name varchar(50) not null,
+    slug varchar(60) not null unique,
+    created_by integer,
+    created_at timestamp,
+    CONSTRAINT fk_created_by
+      FOREIGN KEY(created_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+CREATE UNIQUE INDEX tags_name_idx ON tags (lower(name));
+
+-- Create movie_tags table inside the database, the tags of every movie
+CREATE TABLE movie_tags (
+    movie_id integer not null,
+    tag_id integer not null,
+    added_by integer,
+    created_at timestamp,
+    PRIMARY KEY (movie_id, tag_id),
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_tag_id
+      FOREIGN KEY(tag_id)
+      REFERENCES tags(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_added_by
+      FOREIGN KEY(added_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+CREATE INDEX movie_tags_tag_id_idx ON movie_tags (tag_id);
+
+-- Create tag_suggestions table inside the database, the tags users suggest for a movie until a moderator
+-- approves or rejects them
+CREATE TABLE tag_suggestions (
+    id serial not null primary key,
+    movie_id integer not null,
+    user_id integer not null,
+    tag_name varchar(50) not null,
+    status varchar(20) not null default 'pending',
+    reviewed_by integer,
+    reviewed_at timestamp,
+    created_at timestamp,
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_user_id
+      FOREIGN KEY(user_id)
+      REFERENCES users(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_reviewed_by
+      FOREIGN KEY(reviewed_by)
+      REFERENCES users(id)
+      ON DELETE SET NULL
+);
+CREATE UNIQUE INDEX tag_suggestions_pending_idx ON tag_suggestions (movie_id, lower(tag_name)) WHERE status = 'pending';
+CREATE INDEX tag_suggestions_status_idx ON tag_suggestions (status, created_at);
+
+-- Create movie_certifications table inside the database, the content rating of a movie in each country with
+-- the age it is meant for
+CREATE TABLE movie_certifications (
+    movie_id integer not null,
+    country char(2) not null,
+    certification varchar(10) not null,
+    min_age integer not null default 0,
+    PRIMARY KEY (movie_id, country),
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX movie_certifications_country_idx ON movie_certifications (country, min_age);
+
+-- the highest certification a user wants to see, empty when there is no limit
+ALTER TABLE users ADD COLUMN certification_country varchar(2) not null default '';
+ALTER TABLE users ADD COLUMN max_certification varchar(10) not null default '';
+
+-- the extended metadata of the movies
+ALTER TABLE movies ADD COLUMN original_title varchar(255) not null default '';
+ALTER TABLE movies ADD COLUMN tagline varchar(255) not null default '';
+ALTER TABLE movies ADD COLUMN budget bigint not null default 0;
+ALTER TABLE movies ADD COLUMN revenue bigint not null default 0;
+ALTER TABLE movies ADD COLUMN imdb_id varchar(12);
+ALTER TABLE movies ADD COLUMN tmdb_id integer;
+CREATE UNIQUE INDEX movies_imdb_id_idx ON movies (imdb_id);
+CREATE UNIQUE INDEX movies_tmdb_id_idx ON movies (tmdb_id);
+
+-- Create movie_languages table inside the database, the ISO 639-1 codes of the languages spoken in a movie
+CREATE TABLE movie_languages (
+    movie_id integer not null,
+    language char(2) not null,
+    position integer not null default 0,
+    PRIMARY KEY (movie_id, language),
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX movie_languages_language_idx ON movie_languages (language);
+
+-- Create movie_countries table inside the database, the ISO 3166-1 codes of the countries that produced a movie
+CREATE TABLE movie_countries (
+    movie_id integer not null,
+    country char(2) not null,
+    position integer not null default 0,
+    PRIMARY KEY (movie_id, country),
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX movie_countries_country_idx ON movie_countries (country);
+
+-- Create studios table inside the database, the companies that produce the movies
+CREATE TABLE studios (
+    id serial not null primary key,
+    name varchar(100) not null,
+    slug varchar(100) not null unique,
+    created_at timestamp,
+    updated_at timestamp
+);
+
+-- Create movie_studios table inside the database
+CREATE TABLE movie_studios (
+    movie_id integer not null,
+    studio_id integer not null,
+    position integer not null default 0,
+    PRIMARY KEY (movie_id, studio_id),
+    CONSTRAINT fk_movie_id
+      FOREIGN KEY(movie_id)
+      REFERENCES movies(id)
+      ON DELETE CASCADE,
+    CONSTRAINT fk_studio_id
+      FOREIGN KEY(studio_id)
+      REFERENCES studios(id)
+      ON DELETE CASCADE
+);
+CREATE INDEX movie_studios_studio_id_idx ON movie_studios (studio_id);
+
+-- Alter table comments and reviews add hidden_by_reports, dismissing the reports only shows again what the reports hid
+ALTER TABLE comments ADD COLUMN hidden_by_reports boolean not null default false;
+ALTER TABLE reviews ADD COLUMN hidden_by_reports boolean not null default false;
+
+-- Alter table user_sanctions add recounted_at, set once the comment counts caught up with a shadow mute that ended on its own
+ALTER TABLE user_sanctions ADD COLUMN recounted_at timestamp;
+CREATE INDEX user_sanctions_ended_mutes_idx ON user_sanctions (ends_at)
+    WHERE kind = 'shadow_mute' AND revoked_at IS NULL AND recounted_at IS NULL;
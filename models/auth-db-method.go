@@ -39,3 +39,78 @@ func (m *DBModel) GetUserByEmail(email string) (*User, error) {
 
 	return u, nil
 }
+
+// GetUserPreferences gets the preferences of a user
+func (m *DBModel) GetUserPreferences(userID int) (*UserPreferences, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `SELECT show_spoilers, is_private, certification_country, max_certification FROM users WHERE id = $1`
+
+	var prefs UserPreferences
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(
+		&prefs.ShowSpoilers,
+		&prefs.PrivateProfile,
+		&prefs.CertificationCountry,
+		&prefs.MaxCertification,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &prefs, nil
+}
+
+// UpdateUserPreferences saves the preferences of a user, a public profile accepts its pending follow requests
+func (m *DBModel) UpdateUserPreferences(userID int, prefs *UserPreferences) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to save the preferences")
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET show_spoilers = $1, is_private = $2, certification_country = $3, max_certification = $4,
+		updated_at = $5
+	WHERE id = $6`
+
+	_, err = tx.ExecContext(ctx, stmt, prefs.ShowSpoilers, prefs.PrivateProfile, prefs.CertificationCountry,
+		prefs.MaxCertification, time.Now(), userID)
+	if err != nil {
+		return errors.New("failed to save the preferences")
+	}
+
+	if !prefs.PrivateProfile {
+		stmt = `UPDATE follows SET status = $1 WHERE followee_id = $2 AND status = $3`
+		_, err = tx.ExecContext(ctx, stmt, FollowAccepted, userID, FollowPending)
+		if err != nil {
+			return errors.New("failed to save the preferences")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to save the preferences")
+	}
+
+	return nil
+}
+
+// GetUser gets user by id
+func (m *DBModel) GetUser(id int) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `SELECT id, name, email, user_type FROM users WHERE id = $1`
+
+	u := &User{}
+
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&u.ID, &u.FullName, &u.Email, &u.UserType)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Certification is a content rating of a country and the age it is meant for
+type Certification struct {
+	Code   string `json:"code"`
+	MinAge int    `json:"min_age"`
+}
+
+// CertificationSystems are the content ratings of each country, from the mildest to the strictest
+var CertificationSystems = map[string][]Certification{
+	"US": {{"G", 0}, {"PG", 8}, {"PG-13", 13}, {"R", 17}, {"NC-17", 18}},
+	"GB": {{"U", 0}, {"PG", 8}, {"12A", 12}, {"12", 12}, {"15", 15}, {"18", 18}, {"R18", 18}},
+	"DE": {{"0", 0}, {"6", 6}, {"12", 12}, {"16", 16}, {"18", 18}},
+	"FR": {{"U", 0}, {"10", 10}, {"12", 12}, {"16", 16}, {"18", 18}},
+	"IN": {{"U", 0}, {"UA", 12}, {"A", 18}, {"S", 18}},
+	"AU": {{"G", 0}, {"PG", 8}, {"M", 15}, {"MA15+", 15}, {"R18+", 18}, {"X18+", 18}},
+}
+
+// CertificationAge returns the age a certification of the country is meant for, ok is false when the country
+// or the certification is unknown
+func CertificationAge(country, code string) (age int, ok bool) {
+	for _, c := range CertificationSystems[strings.ToUpper(country)] {
+		if strings.EqualFold(c.Code, code) {
+			return c.MinAge, true
+		}
+	}
+
+	return 0, false
+}
+
+// CertificationLimit hides the movies certified for an older audience than MaxAge in Country
+type CertificationLimit struct {
+	Country string
+	MaxAge  int
+}
+
+// CertificationLimit returns the limit set by the preferences, nil when there is none
+func (p *UserPreferences) CertificationLimit() *CertificationLimit {
+	age, ok := CertificationAge(p.CertificationCountry, p.MaxCertification)
+	if !ok {
+		return nil
+	}
+
+	return &CertificationLimit{Country: strings.ToUpper(p.CertificationCountry), MaxAge: age}
+}
+
+// certificationAllowed returns the condition that the movie whose id is column is allowed by the limit,
+// appending its parameters to args. Movies with no certification in the country of the limit are allowed
+func certificationAllowed(column string, limit *CertificationLimit, args *[]interface{}) string {
+	if limit == nil {
+		return "TRUE"
+	}
+
+	*args = append(*args, limit.Country, limit.MaxAge)
+	return fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM movie_certifications mc WHERE mc.movie_id = %s AND mc.country = $%d AND mc.min_age > $%d)`,
+		column, len(*args)-1, len(*args))
+}
+
+// SetMovieCertifications is help to replace the certifications of a movie, keyed by country
+func (m *DBModel) SetMovieCertifications(movieID int, certifications map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for country, code := range certifications {
+		if _, ok := CertificationAge(country, code); !ok {
+			return fmt.Errorf("unknown certification %s for %s", code, country)
+		}
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to save the certifications")
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, `select id from movies where id = $1 for update`, movieID).Scan(&id)
+	if err != nil {
+		return errors.New("invalid movie id")
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from movie_certifications where movie_id = $1`, movieID)
+	if err != nil {
+		return errors.New("failed to save the certifications")
+	}
+
+	stmt := `insert into movie_certifications (movie_id, country, certification, min_age) values($1, $2, $3, $4)`
+	for country, code := range certifications {
+		country = strings.ToUpper(country)
+		age, _ := CertificationAge(country, code)
+
+		// store the code the way the system spells it
+		for _, c := range CertificationSystems[country] {
+			if strings.EqualFold(c.Code, code) {
+				code = c.Code
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, stmt, movieID, country, code, age)
+		if err != nil {
+			return errors.New("failed to save the certifications")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to save the certifications")
+	}
+
+	return nil
+}
+
+// movieCertifications returns the certifications of a movie keyed by country
+func (m *DBModel) movieCertifications(ctx context.Context, movieID int) (map[string]string, error) {
+	rows, err := m.DB.QueryContext(ctx, `select country, certification from movie_certifications where movie_id = $1`, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certifications := make(map[string]string)
+	for rows.Next() {
+		var country, code string
+		err = rows.Scan(&country, &code)
+		if err != nil {
+			return nil, err
+		}
+		certifications[country] = code
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return certifications, nil
+}
@@ -0,0 +1,410 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CollectionMaxMovies is the number of movies a collection can hold
+const CollectionMaxMovies = 100
+
+var (
+	// ErrCollectionNotFound is returned when the collection does not exist, or is not published yet
+	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrCollectionSlugTaken is returned when another collection has the slug
+	ErrCollectionSlugTaken = errors.New("another collection has this slug")
+	// ErrCollectionTooLarge is returned when a collection is given more than CollectionMaxMovies movies
+	ErrCollectionTooLarge = fmt.Errorf("a collection can hold up to %d movies", CollectionMaxMovies)
+	// ErrCollectionDuplicateMovie is returned when a movie is given twice for a collection
+	ErrCollectionDuplicateMovie = errors.New("a movie can be only once in a collection")
+)
+
+// collectionQuery selects a collection with the number of movies in it, it is published when its publish_at
+// is at or before the $1 parameter
+const collectionQuery = `SELECT
+	c.id, c.title, c.slug, c.description, c.cover_image, c.publish_at, COALESCE(c.publish_at <= $1::timestamp, false),
+	(SELECT COUNT(ci.id) FROM collection_items ci WHERE ci.collection_id = c.id), c.created_at, c.updated_at
+FROM
+	collections c`
+
+// scanCollection reads a row selected by collectionQuery
+func scanCollection(row interface{ Scan(...interface{}) error }) (*Collection, error) {
+	var c Collection
+	var cover sql.NullString
+	var publishAt sql.NullTime
+	err := row.Scan(
+		&c.ID,
+		&c.Title,
+		&c.Slug,
+		&c.Description,
+		&cover,
+		&publishAt,
+		&c.Published,
+		&c.MovieCount,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.CoverImage = movieImage(cover)
+	if publishAt.Valid {
+		c.PublishAt = &publishAt.Time
+	}
+
+	return &c, nil
+}
+
+// collectionSlugTaken tells whether a collection other than id has the slug
+func collectionSlugTaken(ctx context.Context, tx *sql.Tx, slug string, id int) (bool, error) {
+	var taken bool
+	err := tx.QueryRowContext(ctx, `select exists (select 1 from collections where slug = $1 and id <> $2)`, slug, id).Scan(&taken)
+	return taken, err
+}
+
+// setCollectionSlug turns the slug of the collection, or its title when the slug is empty, into a slug
+func setCollectionSlug(c *Collection) error {
+	slug := c.Slug
+	if slug == "" {
+		slug = c.Title
+	}
+
+	c.Slug = slugify(slug)
+	if c.Slug == "" {
+		return errors.New("the slug should have letters or digits")
+	}
+
+	return nil
+}
+
+// InsertCollection is help to add a collection, its slug is generated from the title when it is empty
+func (m *DBModel) InsertCollection(c *Collection, cover string, adminID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := setCollectionSlug(c)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to add the collection")
+	}
+	defer tx.Rollback()
+
+	taken, err := collectionSlugTaken(ctx, tx, c.Slug, 0)
+	if err != nil {
+		return 0, errors.New("failed to add the collection")
+	}
+	if taken {
+		return 0, ErrCollectionSlugTaken
+	}
+
+	var id int
+	stmt := `insert into collections (title, slug, description, cover_image, publish_at, created_by, created_at, updated_at)
+	values($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $7) RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt, c.Title, c.Slug, c.Description, cover, c.PublishAt, adminID, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, errors.New("failed to add the collection")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to add the collection")
+	}
+
+	return id, nil
+}
+
+// UpdateCollection is help to update the title, slug, description, cover image and publish date of a
+// collection. An empty cover keeps the current one
+func (m *DBModel) UpdateCollection(c *Collection, cover string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := setCollectionSlug(c)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to update the collection")
+	}
+	defer tx.Rollback()
+
+	taken, err := collectionSlugTaken(ctx, tx, c.Slug, c.ID)
+	if err != nil {
+		return errors.New("failed to update the collection")
+	}
+	if taken {
+		return ErrCollectionSlugTaken
+	}
+
+	stmt := `update collections set title = $1, slug = $2, description = $3,
+		cover_image = COALESCE(NULLIF($4, ''), cover_image), publish_at = $5, updated_at = $6
+	where id = $7`
+
+	result, err := tx.ExecContext(ctx, stmt, c.Title, c.Slug, c.Description, cover, c.PublishAt, time.Now(), c.ID)
+	if err != nil {
+		return errors.New("failed to update the collection")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCollectionNotFound
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to update the collection")
+	}
+
+	return nil
+}
+
+// DeleteCollection is help to delete a collection with its movies
+func (m *DBModel) DeleteCollection(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from collections where id = $1`, id)
+	if err != nil {
+		return errors.New("failed to delete the collection")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCollectionNotFound
+	}
+
+	return nil
+}
+
+// SetCollectionMovies is help to replace the movies of a collection with movieIDs, in their order
+func (m *DBModel) SetCollectionMovies(id int, movieIDs []int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if len(movieIDs) > CollectionMaxMovies {
+		return ErrCollectionTooLarge
+	}
+
+	seen := make(map[int]bool)
+	for _, movieID := range movieIDs {
+		if seen[movieID] {
+			return ErrCollectionDuplicateMovie
+		}
+		seen[movieID] = true
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to update the movies of the collection")
+	}
+	defer tx.Rollback()
+
+	// lock the collection so its movies are replaced one writer at a time
+	var locked int
+	err = tx.QueryRowContext(ctx, `select id from collections where id = $1 for update`, id).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrCollectionNotFound
+	}
+	if err != nil {
+		return errors.New("failed to update the movies of the collection")
+	}
+
+	var found int
+	err = tx.QueryRowContext(ctx, `select count(id) from movies where id = ANY($1)`, pq.Array(movieIDs)).Scan(&found)
+	if err != nil {
+		return errors.New("failed to update the movies of the collection")
+	}
+	if found != len(movieIDs) {
+		return errors.New("invalid movie id")
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from collection_items where collection_id = $1`, id)
+	if err != nil {
+		return errors.New("failed to update the movies of the collection")
+	}
+
+	stmt := `insert into collection_items (collection_id, movie_id, position) values($1, $2, $3)`
+	for i, movieID := range movieIDs {
+		_, err = tx.ExecContext(ctx, stmt, id, movieID, i+1)
+		if err != nil {
+			return errors.New("failed to update the movies of the collection")
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `update collections set updated_at = $1 where id = $2`, time.Now(), id)
+	if err != nil {
+		return errors.New("failed to update the movies of the collection")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to update the movies of the collection")
+	}
+
+	return nil
+}
+
+// GetCollectionBySlug returns a collection with all of its movies in their order. Drafts and collections
+// scheduled later are only returned when drafts is set. Movies above the certification limit are left out
+func (m *DBModel) GetCollectionBySlug(slug string, drafts bool, cert *CertificationLimit) (*Collection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where := ` WHERE c.slug = $2`
+	if !drafts {
+		where += ` AND c.publish_at <= $1::timestamp`
+	}
+
+	c, err := scanCollection(m.DB.QueryRowContext(ctx, collectionQuery+where, time.Now(), slug))
+	if err == sql.ErrNoRows {
+		return nil, ErrCollectionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	movies, err := m.collectionMovies(ctx, []int{c.ID}, CollectionMaxMovies, cert)
+	if err != nil {
+		return nil, err
+	}
+	c.Movies = movies[c.ID]
+
+	return c, nil
+}
+
+// GetCollections returns a page of the collections with the first preview movies of each, the latest
+// published first. Drafts and collections scheduled later are only returned when drafts is set, before the
+// published ones. Movies above the certification limit are left out of the previews
+func (m *DBModel) GetCollections(page, perPage, preview int, drafts bool, cert *CertificationLimit) (*PaginatedCollections, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+	now := time.Now()
+
+	var err error
+	var totalCount int
+	where := ""
+	if drafts {
+		err = m.DB.QueryRowContext(ctx, `SELECT COUNT(c.id) FROM collections c`).Scan(&totalCount)
+	} else {
+		where = ` WHERE c.publish_at <= $1::timestamp`
+		err = m.DB.QueryRowContext(ctx, `SELECT COUNT(c.id) FROM collections c`+where, now).Scan(&totalCount)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := collectionQuery + where + ` ORDER BY c.publish_at DESC NULLS FIRST, c.id DESC` +
+		fmt.Sprintf(" LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+	var ids []int
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+		ids = append(ids, c.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if preview > 0 {
+		movies, err := m.collectionMovies(ctx, ids, preview, cert)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range collections {
+			c.Movies = movies[c.ID]
+		}
+	}
+
+	return &PaginatedCollections{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Collections: collections,
+	}, nil
+}
+
+// collectionMovies returns up to limit movies of each collection allowed by the certification limit in their
+// order, keyed by the collection id
+func (m *DBModel) collectionMovies(ctx context.Context, ids []int, limit int, cert *CertificationLimit) (map[int][]*Movie, error) {
+	movies := make(map[int][]*Movie, len(ids))
+	if len(ids) == 0 {
+		return movies, nil
+	}
+
+	args := []interface{}{pq.Array(ids), limit}
+	query := `SELECT ci.collection_id, m.id, m.title, m.image, m.description, m.year, m.release_date,
+		` + ratingColumns + `,
+		m.runtime
+	FROM
+		(SELECT collection_id, movie_id, position,
+			ROW_NUMBER() OVER (PARTITION BY collection_id ORDER BY position ASC, id ASC) AS n
+		FROM collection_items
+		WHERE collection_id = ANY($1) AND ` + certificationAllowed("movie_id", cert, &args) + `) ci
+		JOIN movies m ON (m.id = ci.movie_id)` + ratingStatsQuery + `
+	WHERE ci.n <= $2
+	ORDER BY ci.collection_id, ci.n`
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var collectionID int
+		var movie Movie
+		var image sql.NullString
+		var rating, weighted sql.NullFloat64
+		err = rows.Scan(
+			&collectionID,
+			&movie.ID,
+			&movie.Title,
+			&image,
+			&movie.Description,
+			&movie.Year,
+			&movie.ReleaseDate,
+			&rating,
+			&weighted,
+			&movie.VoteCount,
+			&movie.Runtime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		setRating(&movie, rating, weighted)
+		movie.Image = movieImage(image)
+
+		movies[collectionID] = append(movies[collectionID], &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
@@ -0,0 +1,788 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/raihan2bd/filmwise/spoiler"
+)
+
+const (
+	// MaxCommentDepth is the deepest level a reply can be nested at, top level comments are depth 0
+	MaxCommentDepth = 5
+
+	// DeletedCommentText is shown in place of a removed comment that still has replies
+	DeletedCommentText = "[deleted]"
+
+	// CommentsPreviewSize is the number of comments embedded in the movie details
+	CommentsPreviewSize = 3
+)
+
+// sort options for comments listing
+const (
+	CommentSortNewest = "newest"
+	CommentSortOldest = "oldest"
+	CommentSortTop    = "top"
+)
+
+// reactions a user can leave on a comment
+const (
+	ReactionLike    = "like"
+	ReactionHelpful = "helpful"
+)
+
+// commentReactionsQuery counts the reactions of the comment aliased as c
+const commentReactionsQuery = `SELECT
+	COUNT(id) FILTER (WHERE reaction = 'like') AS likes,
+	COUNT(id) FILTER (WHERE reaction = 'helpful') AS helpful
+FROM comment_reactions WHERE comment_id = c.id`
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// commentCursor is the position of the last comment of a page. Top comments keep the time their scores were
+// decayed to on the first page, so the scores of the next pages don't drift from the one in the cursor
+type commentCursor struct {
+	Score     float64   `json:"s,omitempty"`
+	RankedAt  time.Time `json:"r"`
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+// nullInt converts a zero id to a NULL database value
+func nullInt(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id > 0}
+}
+
+// setEditedAt marks a comment as edited when it has an edit time
+func setEditedAt(comment *Comment, editedAt sql.NullTime) {
+	if editedAt.Valid {
+		comment.Edited = true
+		comment.EditedAt = &editedAt.Time
+	}
+}
+
+// maskDeletedComment hides the content and the author of a removed or hidden comment
+func maskDeletedComment(comment *Comment) {
+	if comment.IsDeleted {
+		comment.Comment = DeletedCommentText
+		comment.Segments = nil
+		comment.UserID = 0
+		comment.UserName = ""
+	}
+}
+
+// encodeSegments parses the spoiler markup of a text into segments stored as json
+func encodeSegments(text string) string {
+	js, _ := json.Marshal(spoiler.Parse(text))
+	return string(js)
+}
+
+// decodeSegments reads the segments stored by encodeSegments, text saved before segments existed is parsed again
+func decodeSegments(raw []byte, text string) []spoiler.Segment {
+	var segments []spoiler.Segment
+	if len(raw) == 0 || json.Unmarshal(raw, &segments) != nil {
+		return spoiler.Parse(text)
+	}
+
+	return segments
+}
+
+// RenderSpoilers sets the text of a comment and its replies from their segments, spoilers are redacted unless revealed
+func (c *Comment) RenderSpoilers(reveal bool) {
+	if !c.IsDeleted {
+		segments := c.Segments
+		if segments == nil {
+			segments = spoiler.Parse(c.Comment)
+		}
+
+		if !reveal {
+			segments = spoiler.Redact(segments)
+		}
+
+		c.Segments = segments
+		c.Comment = spoiler.Text(segments)
+	}
+
+	for _, reply := range c.Replies {
+		reply.RenderSpoilers(reveal)
+	}
+}
+
+// CheckComment returns comment_id and error, if any
+func (m *DBModel) CheckComment(commentID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int
+	query := `select id from comments where id = $1`
+
+	err := m.DB.QueryRowContext(ctx, query, commentID).Scan(&id)
+	if err != nil {
+		return id, errors.New("invalid comment id")
+	}
+
+	return id, nil
+}
+
+// Get Comment returns one comment and error, if any
+func (m *DBModel) GetComment(id int) (*Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select id, coalesce(parent_id, 0), movie_id, user_id, comment, depth, is_deleted, is_hidden, edited_at, created_at, updated_at
+	from comments where id = $1`
+
+	row := m.DB.QueryRowContext(ctx, query, id)
+
+	var comment Comment
+	var editedAt sql.NullTime
+
+	err := row.Scan(
+		&comment.ID,
+		&comment.ParentID,
+		&comment.MovieID,
+		&comment.UserID,
+		&comment.Comment,
+		&comment.Depth,
+		&comment.IsDeleted,
+		&comment.IsHidden,
+		&editedAt,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	setEditedAt(&comment, editedAt)
+
+	return &comment, nil
+}
+
+// InsertComment is help to add a comment or a reply to the database
+func (m *DBModel) InsertComment(comment *Comment) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	depth := 0
+
+	// replies inherit the depth of their parent and must belong to the same movie
+	if comment.ParentID > 0 {
+		var parentMovieID, parentDepth int
+		var parentDeleted bool
+
+		query := `select movie_id, depth, is_deleted or is_hidden from comments where id = $1`
+		err := m.DB.QueryRowContext(ctx, query, comment.ParentID).Scan(&parentMovieID, &parentDepth, &parentDeleted)
+		if err != nil {
+			return 0, errors.New("invalid parent comment id")
+		}
+
+		if parentMovieID != comment.MovieID {
+			return 0, errors.New("parent comment belongs to another movie")
+		}
+
+		if parentDeleted {
+			return 0, errors.New("can't reply to a deleted comment")
+		}
+
+		if parentDepth+1 > MaxCommentDepth {
+			return 0, errors.New("maximum reply depth is reached")
+		}
+
+		depth = parentDepth + 1
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to add the comment")
+	}
+	defer tx.Rollback()
+
+	var commentID int
+	stmt := `insert into comments (movie_id, user_id, parent_id, depth, comment, comment_segments, created_at, updated_at)
+						values($1, $2, $3, $4, $5, $6, $7, $8)
+						RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		comment.MovieID,
+		comment.UserID,
+		nullInt(comment.ParentID),
+		depth,
+		comment.Comment,
+		encodeSegments(comment.Comment),
+		time.Now(),
+		time.Now(),
+	).Scan(&commentID)
+	if err != nil {
+		return commentID, errors.New("failed to add the comment")
+	}
+
+	muted, err := authorShadowMuted(ctx, tx, commentID)
+	if err != nil {
+		return 0, errors.New("failed to add the comment")
+	}
+
+	if !muted {
+		err = updateMovieStats(ctx, tx, comment.MovieID, statsDelta{comments: 1})
+		if err != nil {
+			return 0, errors.New("failed to add the comment")
+		}
+	}
+
+	err = recordActivity(ctx, tx, comment.UserID, ActivityCommented, comment.MovieID, commentID)
+	if err != nil {
+		return 0, errors.New("failed to add the comment")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to add the comment")
+	}
+
+	return commentID, nil
+}
+
+// UpdateComment is help to edit a comment, the replaced text is kept as a revision
+func (m *DBModel) UpdateComment(comment *Comment) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to update the comment")
+	}
+	defer tx.Rollback()
+
+	var previous string
+	query := `select comment from comments where id = $1 and is_deleted = false and is_hidden = false for update`
+	err = tx.QueryRowContext(ctx, query, comment.ID).Scan(&previous)
+	if err != nil {
+		return 0, errors.New("failed to update the comment")
+	}
+
+	// nothing to record if the text did not change
+	if previous == comment.Comment {
+		return comment.ID, nil
+	}
+
+	now := time.Now()
+
+	stmt := `insert into comment_revisions (comment_id, comment, edited_by, created_at) values ($1, $2, $3, $4)`
+	_, err = tx.ExecContext(ctx, stmt, comment.ID, previous, nullInt(comment.UserID), now)
+	if err != nil {
+		return 0, errors.New("failed to update the comment")
+	}
+
+	var commentID int
+	stmt = `update comments set comment = $1, comment_segments = $2, edited_at = $3, updated_at = $3 where id = $4
+	RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		comment.Comment,
+		encodeSegments(comment.Comment),
+		now,
+		comment.ID,
+	).Scan(&commentID)
+	if err != nil {
+		return commentID, errors.New("failed to update the comment")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to update the comment")
+	}
+
+	return commentID, nil
+}
+
+// GetCommentRevisions returns the previous versions of a comment, latest edit first
+func (m *DBModel) GetCommentRevisions(commentID int) ([]*CommentRevision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select r.id, r.comment_id, r.comment, coalesce(r.edited_by, 0), coalesce(u.name, ''), r.created_at
+	from comment_revisions r
+	left join users u on (u.id = r.edited_by)
+	where r.comment_id = $1
+	order by r.created_at desc, r.id desc`
+
+	rows, err := m.DB.QueryContext(ctx, query, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*CommentRevision{}
+	for rows.Next() {
+		var revision CommentRevision
+		err := rows.Scan(
+			&revision.ID,
+			&revision.CommentID,
+			&revision.Comment,
+			&revision.EditedBy,
+			&revision.EditorName,
+			&revision.EditedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, nil
+}
+
+// deleteComment soft deletes a comment in tx and takes it out of the statistics of its movie, deleting a
+// comment that is already deleted does nothing
+func deleteComment(ctx context.Context, tx *sql.Tx, id, deletedBy int, reason string) error {
+	var movieID int
+	var hidden bool
+	stmt := `update comments set is_deleted = true, deleted_at = $1, deleted_by = $2, deletion_reason = $3
+	where id = $4 and is_deleted = false
+	RETURNING movie_id, is_hidden`
+
+	err := tx.QueryRowContext(ctx, stmt, time.Now(), nullInt(deletedBy), sql.NullString{String: reason, Valid: reason != ""}, id).Scan(&movieID, &hidden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	muted, err := authorShadowMuted(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	// a hidden comment or one of a shadow muted user is already left out of the statistics
+	if !hidden && !muted {
+		return updateMovieStats(ctx, tx, movieID, statsDelta{comments: -1})
+	}
+
+	return nil
+}
+
+// DeleteComment is help to soft delete a comment, it records who deleted it and why so it can be restored.
+// A deleted comment stays in its thread as a placeholder while it has replies that are not deleted.
+func (m *DBModel) DeleteComment(id, deletedBy int, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to delete the comment")
+	}
+	defer tx.Rollback()
+
+	err = deleteComment(ctx, tx, id, deletedBy, reason)
+	if err != nil {
+		return errors.New("failed to delete the comment")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to delete the comment")
+	}
+
+	return nil
+}
+
+// RestoreComment is help to undo the deletion of a comment
+func (m *DBModel) RestoreComment(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to restore the comment")
+	}
+	defer tx.Rollback()
+
+	var movieID int
+	var hidden bool
+	stmt := `update comments set is_deleted = false, deleted_at = null, deleted_by = null, deletion_reason = null
+	where id = $1 and is_deleted = true
+	RETURNING movie_id, is_hidden`
+
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&movieID, &hidden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("failed to restore the comment")
+	}
+
+	muted, err := authorShadowMuted(ctx, tx, id)
+	if err != nil {
+		return errors.New("failed to restore the comment")
+	}
+
+	if !hidden && !muted {
+		err = updateMovieStats(ctx, tx, movieID, statsDelta{comments: 1})
+		if err != nil {
+			return errors.New("failed to restore the comment")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to restore the comment")
+	}
+
+	return nil
+}
+
+// shadowMuted returns the condition for the user whose id is column to be shadow muted at now
+func shadowMuted(column, now string) string {
+	return fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM user_sanctions s WHERE s.user_id = %s AND s.kind = '%s'
+			AND s.revoked_at IS NULL AND (s.ends_at IS NULL OR s.ends_at > %s)
+	)`, column, SanctionShadowMute, now)
+}
+
+// removedComment returns the condition for a comment aliased as alias to be left out for the viewer, it is
+// deleted, hidden by moderators or written by a user other than the viewer who is shadow muted at now
+func removedComment(alias, viewer, now string) string {
+	return fmt.Sprintf(`(%[1]s.is_deleted OR %[1]s.is_hidden OR (%[1]s.user_id <> %[2]s AND %[3]s))`,
+		alias, viewer, shadowMuted(alias+".user_id", now))
+}
+
+// authorShadowMuted tells whether the author of a comment is shadow muted. Their comments are left out of the
+// comment count of the movies the same way they are left out of the threads
+func authorShadowMuted(ctx context.Context, tx *sql.Tx, commentID int) (bool, error) {
+	var muted bool
+	query := `select ` + shadowMuted("c.user_id", "$2") + ` from comments c where c.id = $1`
+	err := tx.QueryRowContext(ctx, query, commentID, time.Now()).Scan(&muted)
+	return muted, err
+}
+
+// visibleComment returns the condition for a comment aliased as alias to be shown to the viewer at now, it
+// is either not removed, or it has a reply somewhere below it that is not removed
+func visibleComment(alias, viewer, now string) string {
+	return fmt.Sprintf(`(NOT %s OR EXISTS (
+		WITH RECURSIVE replies AS (
+			SELECT id, user_id, is_deleted, is_hidden FROM comments WHERE parent_id = %s.id
+			UNION ALL
+			SELECT rr.id, rr.user_id, rr.is_deleted, rr.is_hidden FROM comments rr JOIN replies ON (rr.parent_id = replies.id)
+		)
+		SELECT 1 FROM replies WHERE NOT %s
+	))`, removedComment(alias, viewer, now), alias, removedComment("replies", viewer, now))
+}
+
+// pruneDeletedReplies drops deleted replies that have nothing left to show below them,
+// it reports whether the comment itself is still visible
+func pruneDeletedReplies(comment *Comment) bool {
+	var replies []*Comment
+	for _, reply := range comment.Replies {
+		if pruneDeletedReplies(reply) {
+			replies = append(replies, reply)
+		}
+	}
+
+	comment.Replies = replies
+	comment.ReplyCount = len(replies)
+
+	return !comment.IsDeleted || len(replies) > 0
+}
+
+// encodeCommentCursor turns the position of the last comment of a page into an opaque cursor
+func encodeCommentCursor(cursor commentCursor) string {
+	js, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// decodeCommentCursor reads the position encoded by encodeCommentCursor
+func decodeCommentCursor(value string) (*commentCursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor commentCursor
+	err = json.Unmarshal(js, &cursor)
+	if err != nil || cursor.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// ListComments returns one page of the top level comments of a movie
+func (m *DBModel) ListComments(filter *CommentFilter) (*PaginatedComments, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.listComments(ctx, filter)
+}
+
+// listComments pages through top level comments using keyset pagination on the sort column and id
+func (m *DBModel) listComments(ctx context.Context, filter *CommentFilter) (*PaginatedComments, error) {
+	var cursor *commentCursor
+	if filter.Cursor != "" {
+		c, err := decodeCommentCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+
+	// the scores are decayed to the time of the first page
+	now := time.Now()
+	rankedAt := now
+	if cursor != nil && !cursor.RankedAt.IsZero() {
+		rankedAt = cursor.RankedAt
+	}
+
+	dbArgs := []interface{}{filter.MovieID, filter.UserID, now, rankedAt}
+
+	// add where and order by query according to the sort option
+	where := ""
+	orderByQuery := ""
+	switch filter.SortBy {
+	case CommentSortTop:
+		orderByQuery = " ORDER BY c.score DESC, c.id DESC"
+		if cursor != nil {
+			where = " WHERE (c.score, c.id) < ($5, $6)"
+			dbArgs = append(dbArgs, cursor.Score, cursor.ID)
+		}
+	case CommentSortOldest:
+		orderByQuery = " ORDER BY c.created_at ASC, c.id ASC"
+		if cursor != nil {
+			where = " WHERE (c.created_at, c.id) > ($5, $6)"
+			dbArgs = append(dbArgs, cursor.CreatedAt, cursor.ID)
+		}
+	default:
+		orderByQuery = " ORDER BY c.created_at DESC, c.id DESC"
+		if cursor != nil {
+			where = " WHERE (c.created_at, c.id) < ($5, $6)"
+			dbArgs = append(dbArgs, cursor.CreatedAt, cursor.ID)
+		}
+	}
+
+	// top comments are ranked by their reactions, decayed by the age of the comment in hours
+	query := `SELECT c.id, c.user_id, c.comment, c.comment_segments, c.depth, c.is_deleted, c.edited_at, c.created_at, c.updated_at, c.name,
+		c.reply_count, c.likes, c.helpful, c.user_reaction, c.score
+	FROM (
+		SELECT
+			c.id, c.user_id, c.comment, c.comment_segments, c.depth, ` + removedComment("c", "$2", "$3") + ` AS is_deleted,
+			c.edited_at, c.created_at, c.updated_at, u.name,
+			r.reply_count,
+			cr.likes,
+			cr.helpful,
+			COALESCE(ur.reaction, '') AS user_reaction,
+			(cr.likes + 2 * cr.helpful)::float8 /
+				POWER(EXTRACT(EPOCH FROM ($4::timestamp - c.created_at))::float8 / 3600 + 2, 1.5) AS score
+		FROM
+			comments c
+			LEFT JOIN users u ON (u.id = c.user_id)
+			LEFT JOIN LATERAL (SELECT COUNT(rc.id) AS reply_count FROM comments rc
+				WHERE rc.parent_id = c.id AND ` + visibleComment("rc", "$2", "$3") + `) r ON true
+			LEFT JOIN LATERAL (` + commentReactionsQuery + `) cr ON true
+			LEFT JOIN comment_reactions ur ON (ur.comment_id = c.id AND ur.user_id = $2)
+		WHERE
+			c.movie_id = $1 AND c.parent_id IS NULL AND ` + visibleComment("c", "$2", "$3") + `
+	) c`
+
+	// fetch one extra row to know if there is a next page
+	query += where + orderByQuery + fmt.Sprintf(" LIMIT %d", filter.Limit+1)
+
+	rows, err := m.DB.QueryContext(ctx, query, dbArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &PaginatedComments{
+		PerPage:  filter.Limit,
+		Comments: []Comment{},
+	}
+
+	var last commentCursor
+	for rows.Next() {
+		var comment Comment
+		var editedAt sql.NullTime
+		var segments []byte
+		var score float64
+		err := rows.Scan(
+			&comment.ID,
+			&comment.UserID,
+			&comment.Comment,
+			&segments,
+			&comment.Depth,
+			&comment.IsDeleted,
+			&editedAt,
+			&comment.CreatedAt,
+			&comment.UpdatedAt,
+			&comment.UserName,
+			&comment.ReplyCount,
+			&comment.Likes,
+			&comment.HelpfulVotes,
+			&comment.UserReaction,
+			&score,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// the extra row only tells that there is a next page
+		if len(page.Comments) == filter.Limit {
+			page.HasMore = true
+			break
+		}
+
+		comment.HasReacted = comment.UserReaction != ""
+		setEditedAt(&comment, editedAt)
+		comment.Segments = decodeSegments(segments, comment.Comment)
+		maskDeletedComment(&comment)
+		page.Comments = append(page.Comments, comment)
+		last = commentCursor{Score: score, CreatedAt: comment.CreatedAt, ID: comment.ID}
+		if filter.SortBy == CommentSortTop {
+			last.RankedAt = rankedAt
+		}
+	}
+	rows.Close()
+
+	if page.HasMore {
+		page.NextCursor = encodeCommentCursor(last)
+	}
+
+	query = `select count(c.id) from comments c where c.movie_id = $1 and c.parent_id is null and ` + visibleComment("c", "$2", "$3")
+	err = m.DB.QueryRowContext(ctx, query, filter.MovieID, filter.UserID, now).Scan(&page.TotalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// GetCommentThread returns a comment with all of its replies nested under it, as the user sees it
+func (m *DBModel) GetCommentThread(id, userID int) (*Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `WITH RECURSIVE thread AS (
+		SELECT c.id, c.parent_id, c.movie_id, c.user_id, c.comment, c.comment_segments, c.depth, c.is_deleted, c.is_hidden, c.edited_at, c.created_at, c.updated_at
+		FROM comments c
+		WHERE c.id = $1
+		UNION ALL
+		SELECT c.id, c.parent_id, c.movie_id, c.user_id, c.comment, c.comment_segments, c.depth, c.is_deleted, c.is_hidden, c.edited_at, c.created_at, c.updated_at
+		FROM comments c
+		JOIN thread t ON (c.parent_id = t.id)
+	)
+	SELECT c.id, COALESCE(c.parent_id, 0), c.movie_id, c.user_id, c.comment, c.comment_segments, c.depth,
+		` + removedComment("c", "$2", "$3") + `, c.edited_at,
+		c.created_at, c.updated_at, u.name, cr.likes, cr.helpful, COALESCE(ur.reaction, '')
+	FROM thread c
+	LEFT JOIN users u ON (u.id = c.user_id)
+	LEFT JOIN LATERAL (` + commentReactionsQuery + `) cr ON true
+	LEFT JOIN comment_reactions ur ON (ur.comment_id = c.id AND ur.user_id = $2)
+	ORDER BY c.depth, c.created_at`
+
+	rows, err := m.DB.QueryContext(ctx, query, id, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var root *Comment
+	thread := make(map[int]*Comment)
+
+	for rows.Next() {
+		var comment Comment
+		var editedAt sql.NullTime
+		var segments []byte
+		err := rows.Scan(
+			&comment.ID,
+			&comment.ParentID,
+			&comment.MovieID,
+			&comment.UserID,
+			&comment.Comment,
+			&segments,
+			&comment.Depth,
+			&comment.IsDeleted,
+			&editedAt,
+			&comment.CreatedAt,
+			&comment.UpdatedAt,
+			&comment.UserName,
+			&comment.Likes,
+			&comment.HelpfulVotes,
+			&comment.UserReaction,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comment.HasReacted = comment.UserReaction != ""
+		setEditedAt(&comment, editedAt)
+		comment.Segments = decodeSegments(segments, comment.Comment)
+		maskDeletedComment(&comment)
+
+		// rows are ordered by depth so a parent is always seen before its replies
+		thread[comment.ID] = &comment
+		if parent, ok := thread[comment.ParentID]; ok && comment.ID != id {
+			parent.Replies = append(parent.Replies, &comment)
+		}
+
+		if comment.ID == id {
+			root = &comment
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if root == nil || !pruneDeletedReplies(root) {
+		return nil, errors.New("comment not found")
+	}
+
+	return root, nil
+}
+
+// ToggleCommentReaction adds, switches or removes the reaction of a user on a comment.
+// Sending the same reaction again removes it. It returns the reaction the user has now.
+func (m *DBModel) ToggleCommentReaction(commentID, userID int, reaction string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", errors.New("failed to save the reaction")
+	}
+	defer tx.Rollback()
+
+	var current string
+	query := `select reaction from comment_reactions where comment_id = $1 and user_id = $2 for update`
+	err = tx.QueryRowContext(ctx, query, commentID, userID).Scan(&current)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("failed to save the reaction")
+	}
+
+	if current == reaction {
+		stmt := `delete from comment_reactions where comment_id = $1 and user_id = $2`
+		_, err = tx.ExecContext(ctx, stmt, commentID, userID)
+		if err != nil {
+			return "", errors.New("failed to remove the reaction")
+		}
+		reaction = ""
+	} else {
+		stmt := `insert into comment_reactions (comment_id, user_id, reaction, created_at, updated_at)
+		values ($1, $2, $3, $4, $5)
+		on conflict (comment_id, user_id) do update set reaction = excluded.reaction, updated_at = excluded.updated_at`
+		_, err = tx.ExecContext(ctx, stmt, commentID, userID, reaction, time.Now(), time.Now())
+		if err != nil {
+			return "", errors.New("failed to save the reaction")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", errors.New("failed to save the reaction")
+	}
+
+	return reaction, nil
+}
@@ -0,0 +1,293 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DiaryDateLayout is how the day of a viewing is written, dates are sent to the database in this layout so
+// the time zone of the session can't move them to another day
+const DiaryDateLayout = "2006-01-02"
+
+// ErrDiaryEntryNotFound is returned when a diary entry does not exist or belongs to someone else
+var ErrDiaryEntryNotFound = errors.New("diary entry not found")
+
+// diaryQuery selects a diary entry with the title and the image of the movie
+const diaryQuery = `SELECT
+	d.id, d.user_id, d.movie_id, m.title, m.image, d.watched_on, d.rating, d.is_rewatch, d.created_at
+FROM
+	diary_entries d
+	JOIN movies m ON (m.id = d.movie_id)`
+
+// scanDiaryEntry reads a row selected by diaryQuery
+func scanDiaryEntry(row interface{ Scan(...interface{}) error }) (*DiaryEntry, error) {
+	var entry DiaryEntry
+	var image sql.NullString
+	var rating sql.NullFloat64
+	err := row.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.MovieID,
+		&entry.MovieTitle,
+		&image,
+		&entry.WatchedOn,
+		&rating,
+		&entry.IsRewatch,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Image = movieImage(image)
+	entry.Date = entry.WatchedOn.Format(DiaryDateLayout)
+	if rating.Valid {
+		r := float32(rating.Float64)
+		entry.Rating = &r
+	}
+
+	return &entry, nil
+}
+
+// nullRating turns an optional rating into a nullable column
+func nullRating(rating *float32) sql.NullFloat64 {
+	if rating == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(*rating), Valid: true}
+}
+
+// HasWatched tells if the user logged a viewing of the movie before
+func (m *DBModel) HasWatched(userID, movieID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	query := `select exists(select 1 from diary_entries where user_id = $1 and movie_id = $2)`
+	err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(&exists)
+
+	return exists, err
+}
+
+// InsertDiaryEntry is help to log a viewing, the movie is taken off the watchlist of the user
+func (m *DBModel) InsertDiaryEntry(entry *DiaryEntry) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to add the diary entry")
+	}
+	defer tx.Rollback()
+
+	var id int
+	stmt := `insert into diary_entries (user_id, movie_id, watched_on, rating, is_rewatch, created_at, updated_at)
+	select $1, m.id, $3, $4, $5, $6, $6 from movies m where m.id = $2
+	RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		entry.UserID,
+		entry.MovieID,
+		entry.WatchedOn.Format(DiaryDateLayout),
+		nullRating(entry.Rating),
+		entry.IsRewatch,
+		time.Now(),
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("invalid movie id")
+	}
+	if err != nil {
+		return 0, errors.New("failed to add the diary entry")
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from watchlist where user_id = $1 and movie_id = $2`, entry.UserID, entry.MovieID)
+	if err != nil {
+		return 0, errors.New("failed to add the diary entry")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to add the diary entry")
+	}
+
+	return id, nil
+}
+
+// UpdateDiaryEntry is help to change the date and the rating of a diary entry of the user. The date is only
+// changed when entry has one, the rating when entry has one and the rewatch flag when rewatch is given
+func (m *DBModel) UpdateDiaryEntry(entry *DiaryEntry, rewatch *bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var watchedOn sql.NullString
+	if !entry.WatchedOn.IsZero() {
+		watchedOn = sql.NullString{String: entry.WatchedOn.Format(DiaryDateLayout), Valid: true}
+	}
+
+	var isRewatch sql.NullBool
+	if rewatch != nil {
+		isRewatch = sql.NullBool{Bool: *rewatch, Valid: true}
+	}
+
+	stmt := `update diary_entries set watched_on = COALESCE($1::date, watched_on), rating = COALESCE($2, rating),
+	is_rewatch = COALESCE($3, is_rewatch), updated_at = $4
+	where id = $5 and user_id = $6`
+
+	result, err := m.DB.ExecContext(ctx, stmt, watchedOn, nullRating(entry.Rating), isRewatch, time.Now(), entry.ID, entry.UserID)
+	if err != nil {
+		return errors.New("failed to update the diary entry")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDiaryEntryNotFound
+	}
+
+	return nil
+}
+
+// DeleteDiaryEntry is help to delete a diary entry of the user
+func (m *DBModel) DeleteDiaryEntry(id, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from diary_entries where id = $1 and user_id = $2`, id, userID)
+	if err != nil {
+		return errors.New("failed to delete the diary entry")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDiaryEntryNotFound
+	}
+
+	return nil
+}
+
+// GetDiary returns a page of the diary of the user, the latest viewing first
+func (m *DBModel) GetDiary(userID, page, perPage int) (*PaginatedDiary, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	var totalCount int
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(id) FROM diary_entries WHERE user_id = $1`, userID).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := diaryQuery + ` WHERE d.user_id = $1 ORDER BY d.watched_on DESC, d.id DESC` +
+		fmt.Sprintf(" LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*DiaryEntry{}
+	for rows.Next() {
+		entry, err := scanDiaryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedDiary{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Entries:     entries,
+	}, nil
+}
+
+// GetDiaryMonth returns the viewings of the user in a month grouped by day
+func (m *DBModel) GetDiaryMonth(userID, year, month int) (*DiaryMonth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	query := diaryQuery + ` WHERE d.user_id = $1 AND d.watched_on >= $2 AND d.watched_on < $3
+	ORDER BY d.watched_on ASC, d.id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, from.Format(DiaryDateLayout), to.Format(DiaryDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calendar := &DiaryMonth{Year: year, Month: month, Days: []*DiaryDay{}}
+	var day *DiaryDay
+	for rows.Next() {
+		entry, err := scanDiaryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if day == nil || day.Date != entry.Date {
+			day = &DiaryDay{Date: entry.Date}
+			calendar.Days = append(calendar.Days, day)
+		}
+		day.Entries = append(day.Entries, entry)
+		calendar.Total++
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return calendar, nil
+}
+
+// GetDiaryYear returns the number of viewings of the user in each month of a year
+func (m *DBModel) GetDiaryYear(userID, year int) (*DiaryYear, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(1, 0, 0)
+
+	query := `SELECT EXTRACT(MONTH FROM watched_on)::int, COUNT(id), COUNT(id) FILTER (WHERE is_rewatch)
+	FROM diary_entries
+	WHERE user_id = $1 AND watched_on >= $2 AND watched_on < $3
+	GROUP BY 1`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, from.Format(DiaryDateLayout), to.Format(DiaryDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// every month is listed, the ones without viewings have zero counts
+	summary := &DiaryYear{Year: year}
+	for month := 1; month <= 12; month++ {
+		summary.Months = append(summary.Months, &DiaryMonthCount{Month: month})
+	}
+
+	for rows.Next() {
+		var month, count, rewatches int
+		err = rows.Scan(&month, &count, &rewatches)
+		if err != nil {
+			return nil, err
+		}
+
+		summary.Months[month-1].Count = count
+		summary.Months[month-1].Rewatches = rewatches
+		summary.Total += count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// CountRecentPosts returns how many comments and reviews a user wrote since the given time
+func (m *DBModel) CountRecentPosts(userID int, since time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select
+		(select count(id) from comments where user_id = $1 and created_at > $2) +
+		(select count(id) from reviews where user_id = $1 and created_at > $2)`
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, userID, since).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CountDuplicatePosts returns how many comments and reviews a user wrote since the given time with the same
+// text on any movie, text is expected lowercased with its white space collapsed. A review is compared by its
+// title and body together, the way the content filter checks it
+func (m *DBModel) CountDuplicatePosts(userID int, text string, since time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select
+		(select count(id) from comments where user_id = $1 and created_at > $2 and is_deleted = false
+			and lower(regexp_replace(trim(comment), '\s+', ' ', 'g')) = $3) +
+		(select count(id) from reviews where user_id = $1 and created_at > $2
+			and lower(regexp_replace(trim(title || ' ' || body), '\s+', ' ', 'g')) = $3)`
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, userID, since, text).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
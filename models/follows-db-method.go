@@ -0,0 +1,301 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/raihan2bd/filmwise/spoiler"
+)
+
+// status of a follow
+const (
+	FollowAccepted = "accepted"
+	FollowPending  = "pending" // waits for a private profile to accept it
+)
+
+// verbs of the activities shown in the feed
+const (
+	ActivityRated       = "rated"
+	ActivityReviewed    = "reviewed"
+	ActivityCommented   = "commented"
+	ActivityListUpdated = "list_updated"
+)
+
+var (
+	// ErrFollowSelf is returned when a user follows themselves
+	ErrFollowSelf = errors.New("you can't follow yourself")
+	// ErrFollowRequestNotFound is returned when a follow request to accept or decline does not exist
+	ErrFollowRequestNotFound = errors.New("follow request not found")
+	// ErrProfileNotVisible is returned when the user does not exist or their profile is private to the viewer
+	ErrProfileNotVisible = errors.New("user not found")
+)
+
+// visibleAuthor returns the condition that the user whose id is column has a public profile, or is the viewer
+// given by the parameter number arg, or is followed by them
+func visibleAuthor(column string, arg int) string {
+	return fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM users pu WHERE pu.id = %[1]s AND pu.is_private AND pu.id <> $%[2]d
+		AND NOT EXISTS (SELECT 1 FROM follows pf WHERE pf.followee_id = pu.id AND pf.follower_id = $%[2]d AND pf.status = '%[3]s'))`,
+		column, arg, FollowAccepted)
+}
+
+// CheckProfileVisible returns ErrProfileNotVisible unless the user exists and the viewer can see their activity,
+// a private profile is only seen by its owner and its accepted followers
+func (m *DBModel) CheckProfileVisible(userID, viewerID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var visible bool
+	query := `SELECT ` + visibleAuthor("u.id", 2) + ` FROM users u WHERE u.id = $1`
+	err := m.DB.QueryRowContext(ctx, query, userID, viewerID).Scan(&visible)
+	if err == sql.ErrNoRows {
+		return ErrProfileNotVisible
+	}
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return ErrProfileNotVisible
+	}
+
+	return nil
+}
+
+// recordActivity adds an activity of a user inside the transaction of the write that caused it. An earlier
+// activity with the same verb and target is replaced, so updating a rating or a list bumps it in the feed
+// instead of showing it twice
+func recordActivity(ctx context.Context, tx *sql.Tx, userID int, verb string, movieID, targetID int) error {
+	stmt := `delete from activities where user_id = $1 and verb = $2 and target_id = $3`
+	_, err := tx.ExecContext(ctx, stmt, userID, verb, targetID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `insert into activities (user_id, verb, movie_id, target_id, created_at) values($1, $2, $3, $4, $5)`
+	_, err = tx.ExecContext(ctx, stmt, userID, verb, nullInt(movieID), targetID, time.Now())
+	return err
+}
+
+// FollowUser is help to follow a user, the follow stays pending when the profile is private. Following a user
+// again keeps the first follow. It returns the status of the follow
+func (m *DBModel) FollowUser(followerID, followeeID int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if followerID == followeeID {
+		return "", ErrFollowSelf
+	}
+
+	var status string
+	stmt := `insert into follows (follower_id, followee_id, status, created_at)
+	select $1, u.id, case when u.is_private then $3 else $4 end, $5 from users u where u.id = $2
+	on conflict (follower_id, followee_id) do update set status = follows.status
+	RETURNING status`
+
+	err := m.DB.QueryRowContext(ctx, stmt, followerID, followeeID, FollowPending, FollowAccepted, time.Now()).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", errors.New("invalid user id")
+	}
+	if err != nil {
+		return "", errors.New("failed to follow the user")
+	}
+
+	return status, nil
+}
+
+// UnfollowUser is help to stop following a user or to cancel a follow request
+func (m *DBModel) UnfollowUser(followerID, followeeID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `delete from follows where follower_id = $1 and followee_id = $2`, followerID, followeeID)
+	if err != nil {
+		return errors.New("failed to unfollow the user")
+	}
+
+	return nil
+}
+
+// RespondFollowRequest is help a user to accept or decline a pending follow request, a declined request is
+// removed
+func (m *DBModel) RespondFollowRequest(followeeID, followerID int, accept bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `delete from follows where follower_id = $1 and followee_id = $2 and status = $3`
+	args := []interface{}{followerID, followeeID, FollowPending}
+	if accept {
+		stmt = `update follows set status = $4 where follower_id = $1 and followee_id = $2 and status = $3`
+		args = append(args, FollowAccepted)
+	}
+
+	result, err := m.DB.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return errors.New("failed to answer the follow request")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrFollowRequestNotFound
+	}
+
+	return nil
+}
+
+// GetFollows returns a page of the followers of a user with the given status, or of the users they follow
+// when following is set, the latest first
+func (m *DBModel) GetFollows(userID int, following bool, status string, page, perPage int) (*PaginatedFollows, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	// the user is on one side of the follow and the listed users on the other
+	self, other := "followee_id", "follower_id"
+	if following {
+		self, other = other, self
+	}
+
+	var totalCount int
+	countQuery := fmt.Sprintf(`SELECT COUNT(*) FROM follows f WHERE f.%s = $1 AND f.status = $2`, self)
+	err := m.DB.QueryRowContext(ctx, countQuery, userID, status).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT u.id, u.name, f.status, f.created_at
+	FROM
+		follows f
+		JOIN users u ON (u.id = f.%[2]s)
+	WHERE f.%[1]s = $1 AND f.status = $2
+	ORDER BY f.created_at DESC, u.id DESC
+	LIMIT %[3]d OFFSET %[4]d`, self, other, perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*Follow{}
+	for rows.Next() {
+		var follow Follow
+		err = rows.Scan(
+			&follow.UserID,
+			&follow.UserName,
+			&follow.Status,
+			&follow.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &follow)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedFollows{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Users:       users,
+	}, nil
+}
+
+// feedQuery selects the activities of the users followed by $1 at the time $2, activities whose target is gone
+// or is not visible to the follower are left out: deleted ratings, hidden reviews, removed comments and lists that are
+// not public
+var feedQuery = `FROM
+	activities a
+	JOIN users u ON (u.id = a.user_id)
+	LEFT JOIN movies m ON (m.id = a.movie_id)
+	LEFT JOIN ratings r ON (a.verb = '` + ActivityRated + `' AND r.id = a.target_id)
+	LEFT JOIN reviews rv ON (a.verb = '` + ActivityReviewed + `' AND rv.id = a.target_id AND rv.is_hidden = false)
+	LEFT JOIN comments c ON (a.verb = '` + ActivityCommented + `' AND c.id = a.target_id AND NOT ` + removedComment("c", "$1", "$2") + `)
+	LEFT JOIN user_lists l ON (a.verb = '` + ActivityListUpdated + `' AND l.id = a.target_id AND l.visibility = '` + ListPublic + `')
+WHERE
+	a.user_id IN (SELECT f.followee_id FROM follows f WHERE f.follower_id = $1 AND f.status = '` + FollowAccepted + `')
+	AND (r.id IS NOT NULL OR rv.id IS NOT NULL OR c.id IS NOT NULL OR l.id IS NOT NULL)`
+
+// GetFeed returns a page of the activities of the users followed by the user, the latest first. Private
+// profiles only show up for the followers they accepted
+func (m *DBModel) GetFeed(userID, page, perPage int) (*PaginatedActivities, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+	now := time.Now()
+
+	var totalCount int
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(a.id) `+feedQuery, userID, now).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT a.id, a.user_id, u.name, a.verb, COALESCE(a.movie_id, 0), COALESCE(m.title, ''), a.target_id,
+		r.rating, COALESCE(rv.title, ''), COALESCE(c.comment, ''), COALESCE(l.name, ''), COALESCE(l.slug, ''), a.created_at ` +
+		feedQuery + ` ORDER BY a.created_at DESC, a.id DESC` + fmt.Sprintf(" LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := []*Activity{}
+	for rows.Next() {
+		var activity Activity
+		var rating sql.NullFloat64
+		err = rows.Scan(
+			&activity.ID,
+			&activity.UserID,
+			&activity.UserName,
+			&activity.Verb,
+			&activity.MovieID,
+			&activity.MovieTitle,
+			&activity.TargetID,
+			&rating,
+			&activity.ReviewTitle,
+			&activity.Comment,
+			&activity.ListName,
+			&activity.ListSlug,
+			&activity.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if rating.Valid {
+			r := float32(rating.Float64)
+			activity.Rating = &r
+		}
+		activities = append(activities, &activity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedActivities{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Activities:  activities,
+	}, nil
+}
+
+// RenderSpoilers removes the spoiler markers from the comment of an activity, spoilers are redacted unless revealed
+func (a *Activity) RenderSpoilers(reveal bool) {
+	if a.Comment == "" {
+		return
+	}
+
+	segments := spoiler.Parse(a.Comment)
+	if !reveal {
+		segments = spoiler.Redact(segments)
+	}
+	a.Comment = spoiler.Text(segments)
+}
@@ -0,0 +1,353 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrGenreNotFound is returned when the genre does not exist
+	ErrGenreNotFound = errors.New("genre not found")
+	// ErrGenreNameTaken is returned when another genre has the name
+	ErrGenreNameTaken = errors.New("another genre has this name")
+	// ErrGenreSlugTaken is returned when another genre has the slug
+	ErrGenreSlugTaken = errors.New("another genre has this slug")
+	// ErrGenreParentCycle is returned when a genre is put under itself or one of its sub genres
+	ErrGenreParentCycle = errors.New("a genre can't be put under itself or one of its sub genres")
+	// ErrGenreIsDefault is returned when the default genre is deleted
+	ErrGenreIsDefault = errors.New("set another default genre before deleting this one")
+	// ErrNoDefaultGenre is returned when a movie has no genre, or a deleted genre leaves one without, and no
+	// default genre is set
+	ErrNoDefaultGenre = errors.New("the movie has no genre and no default genre is set")
+)
+
+// genreColumns selects a genre aliased g
+const genreColumns = `g.id, g.genre_name, g.slug, g.description, g.parent_id, g.is_default, g.created_at, g.updated_at`
+
+// scanGenre reads a row selected by genreColumns
+func scanGenre(row interface{ Scan(...interface{}) error }) (*Genre, error) {
+	var g Genre
+	var parentID sql.NullInt64
+	err := row.Scan(
+		&g.ID,
+		&g.GenreName,
+		&g.Slug,
+		&g.Description,
+		&parentID,
+		&g.IsDefault,
+		&g.CreatedAt,
+		&g.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		g.ParentID = &id
+	}
+
+	return &g, nil
+}
+
+// genreTreeQuery selects the id of the genre given by the parameter number arg and of all of its sub genres
+func genreTreeQuery(arg int) string {
+	return fmt.Sprintf(`WITH RECURSIVE tree AS (
+		SELECT id FROM genres WHERE id = $%d
+		UNION
+		SELECT g.id FROM genres g JOIN tree ON (g.parent_id = tree.id)
+	) SELECT id FROM tree`, arg)
+}
+
+// CheckGenre checks if genre exists
+func (m *DBModel) CheckGenre(genreID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select id from genres where id = $1`
+
+	var id int
+	err := m.DB.QueryRowContext(ctx, query, genreID).Scan(&id)
+	if err != nil {
+		return false, err
+	}
+
+	if id <= 0 {
+		return false, errors.New("Genre not found")
+	}
+
+	return true, nil
+}
+
+// saveGenre checks the name, slug and parent of a genre before it is inserted or updated, its slug is
+// generated from the name when it is empty. Setting the genre as the default one unsets the previous default
+func saveGenre(ctx context.Context, tx *sql.Tx, g *Genre) error {
+	slug := g.Slug
+	if slug == "" {
+		slug = g.GenreName
+	}
+	g.Slug = slugify(slug)
+	if g.Slug == "" {
+		return errors.New("the slug should have letters or digits")
+	}
+
+	var nameTaken, slugTaken bool
+	query := `select
+		exists (select 1 from genres where lower(genre_name) = lower($1) and id <> $3),
+		exists (select 1 from genres where slug = $2 and id <> $3)`
+	err := tx.QueryRowContext(ctx, query, g.GenreName, g.Slug, g.ID).Scan(&nameTaken, &slugTaken)
+	if err != nil {
+		return err
+	}
+	if nameTaken {
+		return ErrGenreNameTaken
+	}
+	if slugTaken {
+		return ErrGenreSlugTaken
+	}
+
+	if g.ParentID != nil {
+		var exists, cycle bool
+		query = `select
+			exists (select 1 from genres where id = $1),
+			$1 IN (` + genreTreeQuery(2) + `)`
+		err = tx.QueryRowContext(ctx, query, *g.ParentID, g.ID).Scan(&exists, &cycle)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("invalid parent genre")
+		}
+		if cycle {
+			return ErrGenreParentCycle
+		}
+	}
+
+	if g.IsDefault {
+		_, err = tx.ExecContext(ctx, `update genres set is_default = false where is_default and id <> $1`, g.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InsertGenre inserts a new genre into the database
+func (m *DBModel) InsertGenre(g *Genre) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = saveGenre(ctx, tx, g)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `insert into genres (genre_name, slug, description, parent_id, is_default, created_at, updated_at)
+	values ($1, $2, $3, $4, $5, $6, $6) returning id`
+
+	var id int
+	err = tx.QueryRowContext(ctx, query, g.GenreName, g.Slug, g.Description, g.ParentID, g.IsDefault, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateGenre updates a genre in the database
+func (m *DBModel) UpdateGenre(g *Genre) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return g.ID, err
+	}
+	defer tx.Rollback()
+
+	err = saveGenre(ctx, tx, g)
+	if err != nil {
+		return g.ID, err
+	}
+
+	query := `update genres set genre_name = $1, slug = $2, description = $3, parent_id = $4, is_default = $5,
+		updated_at = $6
+	where id = $7`
+
+	result, err := tx.ExecContext(ctx, query, g.GenreName, g.Slug, g.Description, g.ParentID, g.IsDefault, time.Now(), g.ID)
+	if err != nil {
+		return g.ID, err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return g.ID, ErrGenreNotFound
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return g.ID, err
+	}
+
+	return g.ID, nil
+}
+
+// DeleteGenre deletes a genre from the database, its sub genres move to the top level and the movies it was
+// the only genre of get the default genre
+func (m *DBModel) DeleteGenre(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var isDefault bool
+	err = tx.QueryRowContext(ctx, `select is_default from genres where id = $1 for update`, id).Scan(&isDefault)
+	if err == sql.ErrNoRows {
+		return ErrGenreNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if isDefault {
+		return ErrGenreIsDefault
+	}
+
+	// the movies left without a genre
+	var orphans int
+	query := `select count(mg.id) from movies_genres mg
+	where mg.genre_id = $1 and not exists (select 1 from movies_genres o where o.movie_id = mg.movie_id and o.genre_id <> $1)`
+	err = tx.QueryRowContext(ctx, query, id).Scan(&orphans)
+	if err != nil {
+		return err
+	}
+
+	if orphans > 0 {
+		defaultID, _, err := defaultGenre(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		stmt := `insert into movies_genres (genre_id, movie_id, created_at, updated_at)
+		select $2, mg.movie_id, $3, $3 from movies_genres mg
+		where mg.genre_id = $1 and not exists (select 1 from movies_genres o where o.movie_id = mg.movie_id and o.genre_id <> $1)`
+		_, err = tx.ExecContext(ctx, stmt, id, defaultID, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from genres where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// defaultGenre returns the id and the name of the default genre in tx
+func defaultGenre(ctx context.Context, tx *sql.Tx) (int, string, error) {
+	var id int
+	var name string
+	err := tx.QueryRowContext(ctx, `select id, genre_name from genres where is_default`).Scan(&id, &name)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrNoDefaultGenre
+	}
+
+	return id, name, err
+}
+
+// GenreByID returns a single genre based on the ID provided
+func (m *DBModel) GenreByID(id int) (Genre, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + genreColumns + ` from genres g where g.id = $1`
+
+	g, err := scanGenre(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return Genre{}, err
+	}
+
+	return *g, nil
+}
+
+// GenreBySlug returns a genre with its sub genres
+func (m *DBModel) GenreBySlug(slug string) (*Genre, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + genreColumns + ` from genres g where g.slug = $1`
+
+	g, err := scanGenre(m.DB.QueryRowContext(ctx, query, slug))
+	if err == sql.ErrNoRows {
+		return nil, ErrGenreNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `select `+genreColumns+` from genres g where g.parent_id = $1 order by g.genre_name`, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	g.Children = []*Genre{}
+	for rows.Next() {
+		child, err := scanGenre(rows)
+		if err != nil {
+			return nil, err
+		}
+		g.Children = append(g.Children, child)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Get all genres from db
+func (m *DBModel) GenresAll() ([]*Genre, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select ` + genreColumns + ` from genres g order by g.genre_name`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var genres []*Genre
+
+	for rows.Next() {
+		g, err := scanGenre(rows)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, g)
+	}
+
+	return genres, nil
+}
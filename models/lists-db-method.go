@@ -0,0 +1,563 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// visibility of a user list
+const (
+	ListPublic   = "public"   // shown on the profile of the owner and in the browse listing
+	ListUnlisted = "unlisted" // only reachable by its slug
+	ListPrivate  = "private"  // only the owner can see it
+)
+
+// ListMaxItems is the most movies a list can hold
+const ListMaxItems = 500
+
+var (
+	// ErrListNotFound is returned when a list does not exist or belongs to someone else
+	ErrListNotFound = errors.New("list not found")
+	// ErrListFull is returned when a movie is added to a list holding ListMaxItems movies
+	ErrListFull = fmt.Errorf("a list can hold at most %d movies", ListMaxItems)
+	// ErrListItemExists is returned when a movie is added twice to the same list
+	ErrListItemExists = errors.New("the movie is already in the list")
+	// ErrListItemNotFound is returned when a movie is removed from a list that does not hold it
+	ErrListItemNotFound = errors.New("the movie is not in the list")
+	// ErrListOrderMismatch is returned when a new order does not name every movie of the list exactly once
+	ErrListOrderMismatch = errors.New("the new order should hold every movie of the list exactly once")
+)
+
+// listQuery selects a list with its owner and the number of movies in it
+const listQuery = `SELECT
+	l.id, l.user_id, COALESCE(u.name, ''), l.name, l.slug, l.description, l.visibility, l.view_count,
+	(SELECT COUNT(li.id) FROM user_list_items li WHERE li.list_id = l.id), l.created_at, l.updated_at
+FROM
+	user_lists l
+	LEFT JOIN users u ON (u.id = l.user_id)`
+
+// scanList reads a row selected by listQuery
+func scanList(row interface{ Scan(...interface{}) error }) (*UserList, error) {
+	var list UserList
+	err := row.Scan(
+		&list.ID,
+		&list.UserID,
+		&list.UserName,
+		&list.Name,
+		&list.Slug,
+		&list.Description,
+		&list.Visibility,
+		&list.ViewCount,
+		&list.ItemCount,
+		&list.CreatedAt,
+		&list.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// movieImage returns the url of a movie image, movies without an image get the placeholder
+func movieImage(image sql.NullString) string {
+	if !image.Valid || image.String == "" {
+		return fmt.Sprintf("https://res.cloudinary.com/%s/image/upload/no-thumb.jpg", os.Getenv("CLOUD_NAME"))
+	}
+	return fmt.Sprintf("https://res.cloudinary.com/%s/image/upload/%s", os.Getenv("CLOUD_NAME"), image.String)
+}
+
+// slugify turns a name into lowercase words joined by dashes
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+
+	// cut at 100 letters, not bytes, so a letter is never split
+	slug := []rune(b.String())
+	if len(slug) > 100 {
+		return strings.TrimRight(string(slug[:100]), "-")
+	}
+
+	return string(slug)
+}
+
+// listSlug returns the slug of a new list, the name followed by a random suffix so slugs are unique and
+// private lists can't be guessed
+func listSlug(name string) (string, error) {
+	suffix := make([]byte, 6)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+
+	if slug := slugify(name); slug != "" {
+		return slug + "-" + hex.EncodeToString(suffix), nil
+	}
+
+	return "list-" + hex.EncodeToString(suffix), nil
+}
+
+// InsertList is help to add a list, its slug is generated from the name
+func (m *DBModel) InsertList(list *UserList) (int, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	slug, err := listSlug(list.Name)
+	if err != nil {
+		return 0, "", errors.New("failed to add the list")
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", errors.New("failed to add the list")
+	}
+	defer tx.Rollback()
+
+	var id int
+	stmt := `insert into user_lists (user_id, name, slug, description, visibility, created_at, updated_at)
+	values($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		list.UserID,
+		list.Name,
+		slug,
+		list.Description,
+		list.Visibility,
+		time.Now(),
+		time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, "", errors.New("failed to add the list")
+	}
+
+	err = recordActivity(ctx, tx, list.UserID, ActivityListUpdated, 0, id)
+	if err != nil {
+		return 0, "", errors.New("failed to add the list")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, "", errors.New("failed to add the list")
+	}
+
+	return id, slug, nil
+}
+
+// UpdateList is help to change the name, description and visibility of a list, the visibility is kept when
+// list has none. The slug is kept so shared links keep working
+func (m *DBModel) UpdateList(list *UserList) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to update the list")
+	}
+	defer tx.Rollback()
+
+	stmt := `update user_lists set name = $1, description = $2, visibility = COALESCE(NULLIF($3, ''), visibility), updated_at = $4
+	where id = $5 and user_id = $6`
+
+	result, err := tx.ExecContext(ctx, stmt, list.Name, list.Description, list.Visibility, time.Now(), list.ID, list.UserID)
+	if err != nil {
+		return errors.New("failed to update the list")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrListNotFound
+	}
+
+	err = recordActivity(ctx, tx, list.UserID, ActivityListUpdated, 0, list.ID)
+	if err != nil {
+		return errors.New("failed to update the list")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to update the list")
+	}
+
+	return nil
+}
+
+// DeleteList is help to delete a list of the user with its items
+func (m *DBModel) DeleteList(id, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from user_lists where id = $1 and user_id = $2`, id, userID)
+	if err != nil {
+		return errors.New("failed to delete the list")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrListNotFound
+	}
+
+	return nil
+}
+
+// GetListBySlug returns a list with its movies allowed by the certification limit in order
+func (m *DBModel) GetListBySlug(slug string, cert *CertificationLimit) (*UserList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	list, err := scanList(m.DB.QueryRowContext(ctx, listQuery+` WHERE l.slug = $1`, slug))
+	if err == sql.ErrNoRows {
+		return nil, ErrListNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{list.ID}
+	query := `SELECT li.id, li.movie_id, m.title, m.year, m.image, li.position, li.created_at
+	FROM
+		user_list_items li
+		JOIN movies m ON (m.id = li.movie_id)
+	WHERE li.list_id = $1 AND ` + certificationAllowed("li.movie_id", cert, &args) + `
+	ORDER BY li.position ASC, li.id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list.Items = []*ListItem{}
+	for rows.Next() {
+		var item ListItem
+		var image sql.NullString
+		err = rows.Scan(
+			&item.ID,
+			&item.MovieID,
+			&item.MovieTitle,
+			&item.Year,
+			&image,
+			&item.Position,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.Image = movieImage(image)
+		list.Items = append(list.Items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// CountListView is help to count a visit of a list by someone other than its owner
+func (m *DBModel) CountListView(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `update user_lists set view_count = view_count + 1 where id = $1`, id)
+	return err
+}
+
+// GetUserLists returns the lists of a user, the latest updated first. Only public lists are returned
+// unless all is set
+func (m *DBModel) GetUserLists(userID int, all bool) ([]*UserList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	where := ` WHERE l.user_id = $1`
+	if !all {
+		where += ` AND l.visibility = '` + ListPublic + `'`
+	}
+
+	rows, err := m.DB.QueryContext(ctx, listQuery+where+` ORDER BY l.updated_at DESC, l.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*UserList{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// GetPopularLists returns a page of the public lists, the most viewed first. With a certification limit only the
+// lists holding an allowed movie are kept. The lists of private profiles are left out unless the viewer follows them
+func (m *DBModel) GetPopularLists(page, perPage, viewerID int, cert *CertificationLimit) (*PaginatedLists, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	args := []interface{}{viewerID}
+	where := ` WHERE l.visibility = '` + ListPublic + `' AND ` + visibleAuthor("l.user_id", 1)
+	if cert != nil {
+		where += ` AND EXISTS (SELECT 1 FROM user_list_items li
+			WHERE li.list_id = l.id AND ` + certificationAllowed("li.movie_id", cert, &args) + `)`
+	}
+
+	var totalCount int
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(l.id) FROM user_lists l`+where, args...).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := listQuery + where + ` ORDER BY l.view_count DESC, l.updated_at DESC, l.id DESC` +
+		fmt.Sprintf(" LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*UserList{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedLists{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Lists:       lists,
+	}, nil
+}
+
+// lockList locks a list of the user so its items can be changed one writer at a time
+func lockList(ctx context.Context, tx *sql.Tx, listID, userID int) error {
+	var id int
+	query := `select id from user_lists where id = $1 and user_id = $2 for update`
+	err := tx.QueryRowContext(ctx, query, listID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrListNotFound
+	}
+
+	return err
+}
+
+// touchList sets the update time of a list after its items changed
+func touchList(ctx context.Context, tx *sql.Tx, listID int) error {
+	_, err := tx.ExecContext(ctx, `update user_lists set updated_at = $1 where id = $2`, time.Now(), listID)
+	return err
+}
+
+// AddListItem is help to add a movie at the end of a list of the user
+func (m *DBModel) AddListItem(listID, userID, movieID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to add the movie to the list")
+	}
+	defer tx.Rollback()
+
+	err = lockList(ctx, tx, listID, userID)
+	if err != nil {
+		if errors.Is(err, ErrListNotFound) {
+			return 0, err
+		}
+		return 0, errors.New("failed to add the movie to the list")
+	}
+
+	var count, last int
+	query := `select count(id), coalesce(max(position), 0) from user_list_items where list_id = $1`
+	err = tx.QueryRowContext(ctx, query, listID).Scan(&count, &last)
+	if err != nil {
+		return 0, errors.New("failed to add the movie to the list")
+	}
+
+	if count >= ListMaxItems {
+		return 0, ErrListFull
+	}
+
+	var id int
+	stmt := `insert into user_list_items (list_id, movie_id, position, created_at)
+	select $1, m.id, $3, $4 from movies m where m.id = $2
+	on conflict (list_id, movie_id) do nothing
+	RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt, listID, movieID, last+1, time.Now()).Scan(&id)
+	if err == sql.ErrNoRows {
+		var exists bool
+		_ = tx.QueryRowContext(ctx, `select exists(select 1 from movies where id = $1)`, movieID).Scan(&exists)
+		if !exists {
+			return 0, errors.New("invalid movie id")
+		}
+		return 0, ErrListItemExists
+	}
+	if err != nil {
+		return 0, errors.New("failed to add the movie to the list")
+	}
+
+	err = touchList(ctx, tx, listID)
+	if err != nil {
+		return 0, errors.New("failed to add the movie to the list")
+	}
+
+	err = recordActivity(ctx, tx, userID, ActivityListUpdated, 0, listID)
+	if err != nil {
+		return 0, errors.New("failed to add the movie to the list")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to add the movie to the list")
+	}
+
+	return id, nil
+}
+
+// RemoveListItem is help to take a movie out of a list of the user
+func (m *DBModel) RemoveListItem(listID, userID, movieID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to remove the movie from the list")
+	}
+	defer tx.Rollback()
+
+	err = lockList(ctx, tx, listID, userID)
+	if err != nil {
+		if errors.Is(err, ErrListNotFound) {
+			return err
+		}
+		return errors.New("failed to remove the movie from the list")
+	}
+
+	result, err := tx.ExecContext(ctx, `delete from user_list_items where list_id = $1 and movie_id = $2`, listID, movieID)
+	if err != nil {
+		return errors.New("failed to remove the movie from the list")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrListItemNotFound
+	}
+
+	err = touchList(ctx, tx, listID)
+	if err != nil {
+		return errors.New("failed to remove the movie from the list")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to remove the movie from the list")
+	}
+
+	return nil
+}
+
+// ReorderListItems is help to put the movies of a list of the user in the given order
+func (m *DBModel) ReorderListItems(listID, userID int, movieIDs []int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to reorder the list")
+	}
+	defer tx.Rollback()
+
+	err = lockList(ctx, tx, listID, userID)
+	if err != nil {
+		if errors.Is(err, ErrListNotFound) {
+			return err
+		}
+		return errors.New("failed to reorder the list")
+	}
+
+	rows, err := tx.QueryContext(ctx, `select movie_id from user_list_items where list_id = $1`, listID)
+	if err != nil {
+		return errors.New("failed to reorder the list")
+	}
+
+	current := make(map[int]bool)
+	for rows.Next() {
+		var movieID int
+		if err := rows.Scan(&movieID); err != nil {
+			rows.Close()
+			return errors.New("failed to reorder the list")
+		}
+		current[movieID] = true
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return errors.New("failed to reorder the list")
+	}
+
+	// every movie of the list must be named once and nothing else
+	if len(movieIDs) != len(current) {
+		return ErrListOrderMismatch
+	}
+	seen := make(map[int]bool)
+	for _, movieID := range movieIDs {
+		if !current[movieID] || seen[movieID] {
+			return ErrListOrderMismatch
+		}
+		seen[movieID] = true
+	}
+
+	stmt := `update user_list_items set position = $1 where list_id = $2 and movie_id = $3`
+	for i, movieID := range movieIDs {
+		_, err = tx.ExecContext(ctx, stmt, i+1, listID, movieID)
+		if err != nil {
+			return errors.New("failed to reorder the list")
+		}
+	}
+
+	err = touchList(ctx, tx, listID)
+	if err != nil {
+		return errors.New("failed to reorder the list")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to reorder the list")
+	}
+
+	return nil
+}
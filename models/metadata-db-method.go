@@ -0,0 +1,328 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MovieOrderRevenue orders the movies by their box office, the highest first
+const MovieOrderRevenue = "revenue"
+
+// MovieMaxStudios is the number of studios a movie can have, the same goes for its languages and countries
+const MovieMaxStudios = 10
+
+var (
+	// ErrStudioNotFound is returned when the studio does not exist
+	ErrStudioNotFound = errors.New("studio not found")
+	// ErrStudioNameTaken is returned when another studio has the name
+	ErrStudioNameTaken = errors.New("another studio has this name")
+	// ErrIMDbIDTaken is returned when another movie has the IMDb id
+	ErrIMDbIDTaken = errors.New("another movie has this imdb id")
+	// ErrTMDbIDTaken is returned when another movie has the TMDb id
+	ErrTMDbIDTaken = errors.New("another movie has this tmdb id")
+)
+
+var (
+	languageCode = regexp.MustCompile(`^[a-z]{2}$`)
+	countryCode  = regexp.MustCompile(`^[A-Z]{2}$`)
+	imdbID       = regexp.MustCompile(`^tt[0-9]{7,10}$`)
+)
+
+// LanguageCode returns the ISO 639-1 code of a spoken language, ok is false when it is not one
+func LanguageCode(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return code, languageCode.MatchString(code)
+}
+
+// CountryCode returns the ISO 3166-1 alpha-2 code of a country, ok is false when it is not one
+func CountryCode(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return code, countryCode.MatchString(code)
+}
+
+// ValidIMDbID tells whether id looks like an IMDb title id, like tt0111161
+func ValidIMDbID(id string) bool {
+	return imdbID.MatchString(id)
+}
+
+// errMovieMetadata is returned when the languages, countries or studios of a movie can't be saved
+var errMovieMetadata = errors.New("failed to save the movie languages, countries and studios")
+
+// checkExternalIDs returns an error when a movie other than movie has its IMDb or TMDb id
+func checkExternalIDs(ctx context.Context, tx *sql.Tx, movie *Movie) error {
+	var imdbTaken, tmdbTaken bool
+	query := `select
+		exists (select 1 from movies where imdb_id = $1 and id <> $3),
+		exists (select 1 from movies where tmdb_id = $2 and id <> $3)`
+	err := tx.QueryRowContext(ctx, query, movie.IMDbID, movie.TMDbID, movie.ID).Scan(&imdbTaken, &tmdbTaken)
+	if err != nil {
+		return err
+	}
+	if movie.IMDbID != "" && imdbTaken {
+		return ErrIMDbIDTaken
+	}
+	if movie.TMDbID > 0 && tmdbTaken {
+		return ErrTMDbIDTaken
+	}
+
+	return nil
+}
+
+// externalIDTaken returns ErrIMDbIDTaken or ErrTMDbIDTaken when err is the unique violation of their index, which
+// happens when another movie took the id after checkExternalIDs. It returns nil for any other error
+func externalIDTaken(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return nil
+	}
+
+	switch pqErr.Constraint {
+	case "movies_imdb_id_idx":
+		return ErrIMDbIDTaken
+	case "movies_tmdb_id_idx":
+		return ErrTMDbIDTaken
+	}
+
+	return nil
+}
+
+// StudioSlug returns the slug of a studio name, it is empty when the name has no letters or digits
+func StudioSlug(name string) string {
+	return slugify(name)
+}
+
+// findOrCreateStudio returns the id of the studio with the name, the studio is created when it does not exist
+// yet. Names that only differ by case or punctuation are the same studio
+func findOrCreateStudio(ctx context.Context, tx *sql.Tx, name string) (int, error) {
+	slug := StudioSlug(name)
+	if slug == "" {
+		return 0, errors.New("the studio should have letters or digits")
+	}
+
+	var id int
+	stmt := `insert into studios (name, slug, created_at, updated_at) values($1, $2, $3, $3)
+	on conflict (slug) do update set slug = excluded.slug
+	RETURNING id`
+
+	err := tx.QueryRowContext(ctx, stmt, TagName(name), slug, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// setMovieMetadata replaces the spoken languages, production countries and studios of a movie in tx
+func setMovieMetadata(ctx context.Context, tx *sql.Tx, movieID int, movie *Movie) error {
+	for _, table := range []string{"movie_languages", "movie_countries", "movie_studios"} {
+		_, err := tx.ExecContext(ctx, `delete from `+table+` where movie_id = $1`, movieID)
+		if err != nil {
+			return errMovieMetadata
+		}
+	}
+
+	for i, language := range movie.Languages {
+		stmt := `insert into movie_languages (movie_id, language, position) values($1, $2, $3) on conflict do nothing`
+		_, err := tx.ExecContext(ctx, stmt, movieID, language, i)
+		if err != nil {
+			return errMovieMetadata
+		}
+	}
+
+	for i, country := range movie.Countries {
+		stmt := `insert into movie_countries (movie_id, country, position) values($1, $2, $3) on conflict do nothing`
+		_, err := tx.ExecContext(ctx, stmt, movieID, country, i)
+		if err != nil {
+			return errMovieMetadata
+		}
+	}
+
+	for i, studio := range movie.Studios {
+		if StudioSlug(studio.Name) == "" {
+			return errors.New("the studio should have letters or digits")
+		}
+
+		studioID, err := findOrCreateStudio(ctx, tx, studio.Name)
+		if err != nil {
+			return errMovieMetadata
+		}
+
+		stmt := `insert into movie_studios (movie_id, studio_id, position) values($1, $2, $3) on conflict do nothing`
+		_, err = tx.ExecContext(ctx, stmt, movieID, studioID, i)
+		if err != nil {
+			return errMovieMetadata
+		}
+	}
+
+	return nil
+}
+
+// movieCodes returns the codes of a movie in one of movie_languages or movie_countries, in their order
+func (m *DBModel) movieCodes(ctx context.Context, table, column string, movieID int) ([]string, error) {
+	rows, err := m.DB.QueryContext(ctx, `select `+column+` from `+table+` where movie_id = $1 order by position`, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		err = rows.Scan(&code)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// movieMetadata fills in the spoken languages, production countries and studios of a movie
+func (m *DBModel) movieMetadata(ctx context.Context, movie *Movie) error {
+	var err error
+	movie.Languages, err = m.movieCodes(ctx, "movie_languages", "language", movie.ID)
+	if err != nil {
+		return err
+	}
+
+	movie.Countries, err = m.movieCodes(ctx, "movie_countries", "country", movie.ID)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT s.id, s.name, s.slug, 0
+	FROM
+		movie_studios ms
+		JOIN studios s ON (s.id = ms.studio_id)
+	WHERE ms.movie_id = $1
+	ORDER BY ms.position`
+
+	rows, err := m.DB.QueryContext(ctx, query, movie.ID)
+	if err != nil {
+		return err
+	}
+
+	movie.Studios, err = scanStudios(rows)
+	return err
+}
+
+// scanStudios reads the id, name, slug and movie count of the studios and closes the rows
+func scanStudios(rows *sql.Rows) ([]*Studio, error) {
+	defer rows.Close()
+
+	studios := []*Studio{}
+	for rows.Next() {
+		var s Studio
+		err := rows.Scan(&s.ID, &s.Name, &s.Slug, &s.MovieCount)
+		if err != nil {
+			return nil, err
+		}
+		studios = append(studios, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return studios, nil
+}
+
+// GetStudios returns up to limit studios with the most movies, only the ones whose name has search when it
+// is not empty
+func (m *DBModel) GetStudios(search string, limit int) ([]*Studio, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT s.id, s.name, s.slug, COUNT(ms.movie_id) AS movie_count
+	FROM
+		studios s
+		LEFT JOIN movie_studios ms ON (ms.studio_id = s.id)
+	WHERE s.name ILIKE $1
+	GROUP BY s.id, s.name, s.slug
+	ORDER BY movie_count DESC, s.name ASC
+	LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, "%"+search+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanStudios(rows)
+}
+
+// GetStudioBySlug returns a studio with the number of its movies
+func (m *DBModel) GetStudioBySlug(slug string) (*Studio, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var s Studio
+	query := `SELECT s.id, s.name, s.slug, s.created_at, s.updated_at,
+		(SELECT COUNT(movie_id) FROM movie_studios WHERE studio_id = s.id)
+	FROM studios s
+	WHERE s.slug = $1`
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(&s.ID, &s.Name, &s.Slug, &s.CreatedAt, &s.UpdatedAt, &s.MovieCount)
+	if err == sql.ErrNoRows {
+		return nil, ErrStudioNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// UpdateStudio is help to rename a studio, its slug follows the name
+func (m *DBModel) UpdateStudio(id int, name string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	slug := StudioSlug(name)
+	if slug == "" {
+		return "", errors.New("the studio should have letters or digits")
+	}
+
+	var taken bool
+	err := m.DB.QueryRowContext(ctx, `select exists (select 1 from studios where slug = $1 and id <> $2)`, slug, id).Scan(&taken)
+	if err != nil {
+		return "", errors.New("failed to update the studio")
+	}
+	if taken {
+		return "", ErrStudioNameTaken
+	}
+
+	result, err := m.DB.ExecContext(ctx, `update studios set name = $1, slug = $2, updated_at = $3 where id = $4`,
+		TagName(name), slug, time.Now(), id)
+	if err != nil {
+		return "", errors.New("failed to update the studio")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return "", ErrStudioNotFound
+	}
+
+	return slug, nil
+}
+
+// DeleteStudio is help to delete a studio, it is taken off its movies
+func (m *DBModel) DeleteStudio(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from studios where id = $1`, id)
+	if err != nil {
+		return errors.New("failed to delete the studio")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrStudioNotFound
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/raihan2bd/filmwise/spoiler"
 )
 
 type DBModel struct {
@@ -27,31 +28,112 @@ func NewModels(db *sql.DB, cld *cloudinary.Cloudinary) Models {
 
 // Movie is the type for movies
 type Movie struct {
-	ID             int            `json:"id"`
-	Title          string         `json:"title"`
-	Description    string         `json:"description"`
-	Year           int            `json:"year"`
-	ReleaseDate    time.Time      `json:"release_date"`
-	Runtime        int            `json:"runtime"`
-	Rating         float64        `json:"rating"`
-	Ratings        []Rating       `json:"ratings,omitempty"` // this is for movie details
-	TotalFavorites int            `json:"total_favorites"`   // this is for movie details
-	IsFavorite     bool           `json:"is_favorite"`
-	Favorites      []Favorite     `json:"favorites,omitempty"`
-	TotalComments  int            `json:"total_comments"`
-	Comments       []Comment      `json:"comments,omitempty"` // this is for movie details
-	MovieGenre     map[int]string `json:"genres"`             // this is for movie details
-	Image          string         `json:"image"`
-	CreatedAt      time.Time      `json:"-"`
-	UpdatedAt      time.Time      `json:"-"`
+	ID              int               `json:"id"`
+	Title           string            `json:"title"`
+	OriginalTitle   string            `json:"original_title,omitempty"` // the title in the original language
+	Tagline         string            `json:"tagline,omitempty"`
+	Description     string            `json:"description"`
+	Year            int               `json:"year"`
+	ReleaseDate     time.Time         `json:"release_date"`
+	Runtime         int               `json:"runtime"`
+	Budget          int64             `json:"budget,omitempty"`  // in US dollars
+	Revenue         int64             `json:"revenue,omitempty"` // the worldwide box office in US dollars
+	IMDbID          string            `json:"imdb_id,omitempty"`
+	TMDbID          int               `json:"tmdb_id,omitempty"`
+	Languages       []string          `json:"languages,omitempty"` // the spoken languages, this is for movie details
+	Countries       []string          `json:"countries,omitempty"` // the production countries, this is for movie details
+	Studios         []*Studio         `json:"studios,omitempty"`   // this is for movie details
+	Rating          *float64          `json:"rating"`              // empty until the movie is rated
+	WeightedRating  *float64          `json:"weighted_rating"`
+	VoteCount       int               `json:"vote_count"`
+	UserRating      *float32          `json:"user_rating,omitempty"`      // the rating of the logged in user
+	RatingHistogram []RatingBucket    `json:"rating_histogram,omitempty"` // this is for movie details
+	Ratings         []Rating          `json:"ratings,omitempty"`          // this is for movie details
+	TotalFavorites  int               `json:"total_favorites"`            // this is for movie details
+	IsFavorite      bool              `json:"is_favorite"`
+	InWatchlist     bool              `json:"in_watchlist"`
+	Favorites       []Favorite        `json:"favorites,omitempty"`
+	TotalComments   int               `json:"total_comments"`
+	Comments        []Comment         `json:"comments,omitempty"` // this is for movie details
+	TotalReviews    int               `json:"total_reviews"`
+	MovieGenre      map[int]string    `json:"genres"`                   // this is for movie details
+	Tags            []*Tag            `json:"tags,omitempty"`           // this is for movie details
+	Certifications  map[string]string `json:"certifications,omitempty"` // keyed by country, for movie details
+	TrendingScore   float64           `json:"trending_score,omitempty"`
+	Pinned          bool              `json:"pinned,omitempty"` // pinned by an admin on top of the featured movies
+	Image           string            `json:"image"`
+	CreatedAt       time.Time         `json:"-"`
+	UpdatedAt       time.Time         `json:"-"`
+}
+
+// Recommendation is a movie recommended to a user and where the recommendation comes from
+type Recommendation struct {
+	*Movie
+	Source string `json:"source"`
+}
+
+// FeaturedMovie is a title an admin pinned on top of the featured movies
+type FeaturedMovie struct {
+	MovieID   int       `json:"movie_id"`
+	Title     string    `json:"title"`
+	Image     string    `json:"image"`
+	Position  int       `json:"position"`
+	AddedBy   int       `json:"added_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RatingBucket is the number of votes for a movie between Rating and the next point
+type RatingBucket struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
 }
 
 // Genre is the type for genre
 type Genre struct {
-	ID        int       `json:"id"`
-	GenreName string    `json:"genre_name"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+	ID          int       `json:"id"`
+	GenreName   string    `json:"genre_name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	ParentID    *int      `json:"parent_id"` // empty for a top level genre
+	IsDefault   bool      `json:"is_default"`
+	Children    []*Genre  `json:"children,omitempty"` // this is for genre details
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+// Tag is a free form keyword of movies
+type Tag struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Slug       string    `json:"slug"`
+	MovieCount int       `json:"movie_count,omitempty"`
+	Score      float64   `json:"score,omitempty"`   // how often it goes with another tag, for related tags
+	Related    []*Tag    `json:"related,omitempty"` // this is for tag details
+	CreatedAt  time.Time `json:"-"`
+}
+
+// Studio is a company that produced movies
+type Studio struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Slug       string    `json:"slug"`
+	MovieCount int       `json:"movie_count,omitempty"`
+	CreatedAt  time.Time `json:"-"`
+	UpdatedAt  time.Time `json:"-"`
+}
+
+// TagSuggestion is a tag a user suggested for a movie, waiting for a moderator
+type TagSuggestion struct {
+	ID         int        `json:"id"`
+	MovieID    int        `json:"movie_id"`
+	MovieTitle string     `json:"movie_title"`
+	UserID     int        `json:"user_id"`
+	UserName   string     `json:"user_name"`
+	TagName    string     `json:"tag_name"`
+	Status     string     `json:"status"`
+	ReviewedBy int        `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
 }
 
 // MovieGenre is the type for movie genre
@@ -66,10 +148,25 @@ type MovieGenre struct {
 
 // MovieFilter will help to organize query
 type MovieFilter struct {
-	FindByName    string
-	FilterByGenre int
-	FilterByYear  int
-	OrderBy       string
+	FindByName       string
+	FilterByGenre    int
+	FilterByYear     int
+	FavoritesOf      int // only the favorites of this user
+	FilterByTag      int
+	FilterByStudio   int
+	FilterByLanguage string
+	FilterByCountry  string
+	OrderBy          string
+	Certification    *CertificationLimit // hides the movies above the limit when set
+}
+
+// CommentFilter will help to organize comments query
+type CommentFilter struct {
+	MovieID int
+	UserID  int // the viewer, used to tell if they reacted to a comment
+	SortBy  string
+	Cursor  string
+	Limit   int
 }
 
 // query params helps to organize query parameters
@@ -81,23 +178,113 @@ type QueryParam struct {
 
 // model for rating
 type Rating struct {
-	ID        int       `json:"id"`
-	MovieID   int       `json:"movie_id"`
-	UserID    int       `json:"user_id"`
-	Rating    float32   `json:"rating"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"-"`
+	ID         int       `json:"id"`
+	MovieID    int       `json:"movie_id"`
+	MovieTitle string    `json:"movie_title,omitempty"`
+	UserID     int       `json:"user_id"`
+	Rating     float32   `json:"rating"`
+	CreatedAt  time.Time `json:"-"`
+	UpdatedAt  time.Time `json:"rated_at"`
 }
 
 // model for comment
 type Comment struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	UserName  string    `json:"user_name"`
-	MovieID   int       `json:"movie_id,omitempty"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"commented_at"`
+	ID           int               `json:"id"`
+	ParentID     int               `json:"parent_id,omitempty"`
+	UserID       int               `json:"user_id"`
+	UserName     string            `json:"user_name"`
+	MovieID      int               `json:"movie_id,omitempty"`
+	Comment      string            `json:"comment"`
+	Segments     []spoiler.Segment `json:"segments,omitempty"`
+	Depth        int               `json:"depth"`
+	ReplyCount   int               `json:"reply_count"`
+	Likes        int               `json:"likes"`
+	HelpfulVotes int               `json:"helpful"`
+	HasReacted   bool              `json:"has_reacted"`
+	UserReaction string            `json:"user_reaction,omitempty"`
+	IsDeleted    bool              `json:"is_deleted"`
+	IsHidden     bool              `json:"is_hidden,omitempty"`
+	Edited       bool              `json:"edited"`
+	EditedAt     *time.Time        `json:"edited_at,omitempty"`
+	Replies      []*Comment        `json:"replies,omitempty"` // this is for comment thread
+	CreatedAt    time.Time         `json:"-"`
+	UpdatedAt    time.Time         `json:"commented_at"`
+}
+
+// model for comment revision, it holds the text of a comment before an edit
+type CommentRevision struct {
+	ID         int       `json:"id"`
+	CommentID  int       `json:"comment_id"`
+	Comment    string    `json:"comment"`
+	EditedBy   int       `json:"edited_by"`
+	EditorName string    `json:"editor_name"`
+	EditedAt   time.Time `json:"edited_at"`
+}
+
+// model for review, a long form write up tied to the rating of the user
+type Review struct {
+	ID           int               `json:"id"`
+	MovieID      int               `json:"movie_id"`
+	MovieTitle   string            `json:"movie_title,omitempty"`
+	UserID       int               `json:"user_id"`
+	UserName     string            `json:"user_name"`
+	Rating       float32           `json:"rating"`
+	Title        string            `json:"title"`
+	Body         string            `json:"body"`
+	BodySegments []spoiler.Segment `json:"body_segments"`
+	IsSpoiler    bool              `json:"is_spoiler"`
+	IsHidden     bool              `json:"is_hidden,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// ReviewFilter will help to organize reviews query
+type ReviewFilter struct {
+	MovieID  int
+	UserID   int
+	ViewerID int // the reviews of private profiles are left out unless they follow them
+	SortBy   string
+}
+
+// model for report, a user flags a comment, a review or a profile for moderators to look at
+type Report struct {
+	ID           int        `json:"id"`
+	TargetType   string     `json:"target_type"`
+	TargetID     int        `json:"target_id"`
+	TargetUserID int        `json:"target_user_id"`
+	Content      string     `json:"content,omitempty"` // this is for moderation queue
+	ReporterID   int        `json:"reporter_id"`
+	ReporterName string     `json:"reporter_name,omitempty"`
+	Reason       string     `json:"reason"`
+	Note         string     `json:"note,omitempty"`
+	Status       string     `json:"status"`
+	ReportCount  int        `json:"report_count"` // unresolved reports on the same target
+	ClaimedBy    int        `json:"claimed_by,omitempty"`
+	ClaimedAt    *time.Time `json:"claimed_at,omitempty"`
+	ResolvedBy   int        `json:"resolved_by,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	Resolution   string     `json:"resolution,omitempty"`
+	CreatedAt    time.Time  `json:"reported_at"`
+}
+
+// ReportFilter will help to organize moderation queue query
+type ReportFilter struct {
+	Status     string
+	TargetType string
+}
+
+// model for moderation action, the audit trail of moderation decisions
+type ModerationAction struct {
+	ID            int       `json:"id"`
+	ReportID      int       `json:"report_id,omitempty"`
+	ModeratorID   int       `json:"moderator_id,omitempty"` // empty when the system took the action
+	ModeratorName string    `json:"moderator_name,omitempty"`
+	TargetType    string    `json:"target_type"`
+	TargetID      int       `json:"target_id"`
+	TargetUserID  int       `json:"target_user_id"`
+	Action        string    `json:"action"`
+	Note          string    `json:"note,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 // model for favorite
@@ -109,6 +296,125 @@ type Favorite struct {
 	UpdatedAt time.Time `json:"fav_at"`
 }
 
+// model for user list, a named collection of movies in the order chosen by its owner
+type UserList struct {
+	ID          int         `json:"id"`
+	UserID      int         `json:"user_id"`
+	UserName    string      `json:"user_name"`
+	Name        string      `json:"name"`
+	Slug        string      `json:"slug"`
+	Description string      `json:"description"`
+	Visibility  string      `json:"visibility"`
+	ItemCount   int         `json:"item_count"`
+	ViewCount   int         `json:"view_count"`
+	Items       []*ListItem `json:"items,omitempty"` // this is for list details
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// model for collection, a themed collection of movies built by the admins
+type Collection struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Slug        string     `json:"slug"`
+	Description string     `json:"description"`
+	CoverImage  string     `json:"cover_image"`
+	PublishAt   *time.Time `json:"publish_at"` // empty for a draft
+	Published   bool       `json:"published"`
+	MovieCount  int        `json:"movie_count"`
+	Movies      []*Movie   `json:"movies,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// model for list item, a movie in a user list
+type ListItem struct {
+	ID         int       `json:"id"`
+	MovieID    int       `json:"movie_id"`
+	MovieTitle string    `json:"movie_title"`
+	Year       int       `json:"year"`
+	Image      string    `json:"image"`
+	Position   int       `json:"position"`
+	CreatedAt  time.Time `json:"added_at"`
+}
+
+// model for watchlist item, a movie the user wants to watch
+type WatchlistItem struct {
+	ID         int       `json:"id"`
+	MovieID    int       `json:"movie_id"`
+	MovieTitle string    `json:"movie_title"`
+	Year       int       `json:"year"`
+	Image      string    `json:"image"`
+	CreatedAt  time.Time `json:"added_at"`
+}
+
+// model for diary entry, a viewing of a movie logged by the user
+type DiaryEntry struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	MovieID    int       `json:"movie_id"`
+	MovieTitle string    `json:"movie_title"`
+	Image      string    `json:"image"`
+	WatchedOn  time.Time `json:"-"`
+	Date       string    `json:"watched_on"` // WatchedOn as YYYY-MM-DD
+	Rating     *float32  `json:"rating,omitempty"`
+	IsRewatch  bool      `json:"is_rewatch"`
+	CreatedAt  time.Time `json:"logged_at"`
+}
+
+// DiaryDay holds the viewings of one day
+type DiaryDay struct {
+	Date    string        `json:"date"`
+	Entries []*DiaryEntry `json:"entries"`
+}
+
+// DiaryMonth is the calendar of one month of the diary, days without viewings are left out
+type DiaryMonth struct {
+	Year  int         `json:"year"`
+	Month int         `json:"month"`
+	Total int         `json:"total"`
+	Days  []*DiaryDay `json:"days"`
+}
+
+// DiaryMonthCount is the number of viewings in a month
+type DiaryMonthCount struct {
+	Month     int `json:"month"`
+	Count     int `json:"count"`
+	Rewatches int `json:"rewatches"`
+}
+
+// DiaryYear sums up the diary of a year month by month
+type DiaryYear struct {
+	Year   int                `json:"year"`
+	Total  int                `json:"total"`
+	Months []*DiaryMonthCount `json:"months"`
+}
+
+// model for follow, a user following another one
+type Follow struct {
+	UserID    int       `json:"user_id"` // the follower or the followee, depending on the listing
+	UserName  string    `json:"user_name"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"followed_at"`
+}
+
+// model for activity, something a user did that is shown in the feed of their followers
+type Activity struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	UserName    string    `json:"user_name"`
+	Verb        string    `json:"verb"`
+	MovieID     int       `json:"movie_id,omitempty"`
+	MovieTitle  string    `json:"movie_title,omitempty"`
+	TargetID    int       `json:"target_id"`
+	Rating      *float32  `json:"rating,omitempty"`       // this is for ratings
+	ReviewTitle string    `json:"review_title,omitempty"` // this is for reviews
+	Comment     string    `json:"comment,omitempty"`      // this is for comments
+	ListName    string    `json:"list_name,omitempty"`    // this is for lists
+	ListSlug    string    `json:"list_slug,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 // model for Image
 type Image struct {
 	ID        int       `json:"id"`
@@ -120,6 +426,16 @@ type Image struct {
 	UpdatedAt time.Time `json:"-"`
 }
 
+// UserPreferences holds the settings a user can change for themselves
+type UserPreferences struct {
+	ShowSpoilers   bool `json:"show_spoilers"`
+	PrivateProfile bool `json:"private_profile"`
+	// CertificationCountry and MaxCertification hide the movies certified for an older audience,
+	// both empty when there is no limit
+	CertificationCountry string `json:"certification_country"`
+	MaxCertification     string `json:"max_certification"`
+}
+
 // model for User
 type User struct {
 	ID        int       `json:"id"`
@@ -131,6 +447,22 @@ type User struct {
 	UpdatedAt time.Time `json:"-"`
 }
 
+// model for sanction, a suspension, a ban or a shadow mute of a user
+type Sanction struct {
+	ID           int        `json:"id"`
+	UserID       int        `json:"user_id"`
+	Kind         string     `json:"kind"`
+	Reason       string     `json:"reason"`
+	IssuedBy     int        `json:"issued_by"`
+	IssuerName   string     `json:"issuer_name,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"` // empty when the sanction is permanent
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    int        `json:"revoked_by,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
 // Model for movies response
 type PaginatedMovies struct {
 	TotalCount  int      `json:"total_count"`
@@ -138,3 +470,92 @@ type PaginatedMovies struct {
 	CurrentPage int      `json:"current_page"`
 	Movies      []*Movie `json:"movies"`
 }
+
+// Model for collections response
+type PaginatedCollections struct {
+	TotalCount  int           `json:"total_count"`
+	PerPage     int           `json:"per_page"`
+	CurrentPage int           `json:"current_page"`
+	Collections []*Collection `json:"collections"`
+}
+
+// Model for tag suggestions response
+type PaginatedTagSuggestions struct {
+	TotalCount  int              `json:"total_count"`
+	PerPage     int              `json:"per_page"`
+	CurrentPage int              `json:"current_page"`
+	Suggestions []*TagSuggestion `json:"suggestions"`
+}
+
+// Model for comments response
+type PaginatedComments struct {
+	TotalCount int       `json:"total_count"`
+	PerPage    int       `json:"per_page"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	HasMore    bool      `json:"has_more"`
+	Comments   []Comment `json:"comments"`
+}
+
+// Model for reviews response
+type PaginatedReviews struct {
+	TotalCount  int       `json:"total_count"`
+	PerPage     int       `json:"per_page"`
+	CurrentPage int       `json:"current_page"`
+	Reviews     []*Review `json:"reviews"`
+}
+
+// Model for ratings response
+type PaginatedRatings struct {
+	TotalCount  int       `json:"total_count"`
+	PerPage     int       `json:"per_page"`
+	CurrentPage int       `json:"current_page"`
+	Ratings     []*Rating `json:"ratings"`
+}
+
+// Model for user lists response
+type PaginatedLists struct {
+	TotalCount  int         `json:"total_count"`
+	PerPage     int         `json:"per_page"`
+	CurrentPage int         `json:"current_page"`
+	Lists       []*UserList `json:"lists"`
+}
+
+// Model for watchlist response
+type PaginatedWatchlist struct {
+	TotalCount  int              `json:"total_count"`
+	PerPage     int              `json:"per_page"`
+	CurrentPage int              `json:"current_page"`
+	Movies      []*WatchlistItem `json:"movies"`
+}
+
+// Model for diary response
+type PaginatedDiary struct {
+	TotalCount  int           `json:"total_count"`
+	PerPage     int           `json:"per_page"`
+	CurrentPage int           `json:"current_page"`
+	Entries     []*DiaryEntry `json:"entries"`
+}
+
+// Model for follows response
+type PaginatedFollows struct {
+	TotalCount  int       `json:"total_count"`
+	PerPage     int       `json:"per_page"`
+	CurrentPage int       `json:"current_page"`
+	Users       []*Follow `json:"users"`
+}
+
+// Model for feed response
+type PaginatedActivities struct {
+	TotalCount  int         `json:"total_count"`
+	PerPage     int         `json:"per_page"`
+	CurrentPage int         `json:"current_page"`
+	Activities  []*Activity `json:"activities"`
+}
+
+// Model for reports response
+type PaginatedReports struct {
+	TotalCount  int       `json:"total_count"`
+	PerPage     int       `json:"per_page"`
+	CurrentPage int       `json:"current_page"`
+	Reports     []*Report `json:"reports"`
+}
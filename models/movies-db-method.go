@@ -10,19 +10,48 @@ import (
 	"time"
 )
 
-func (m *DBModel) GetAllMovies(findByName string) ([]*Movie, error) {
+// RatingMinVotes is the number of votes a movie needs before its weighted rating leans on its own average
+const RatingMinVotes = 5
+
+// MovieOrderFavorited orders the favorites of a user by when they were added, the latest first
+const MovieOrderFavorited = "favorited"
+
+// ratingStatsQuery joins the statistics of the movie aliased m from movie_stats as ms, and its average
+// rating and number of votes as rs
+const ratingStatsQuery = ` LEFT JOIN movie_stats ms ON (ms.movie_id = m.id)
+	CROSS JOIN LATERAL (SELECT ms.rating_sum / NULLIF(ms.rating_count, 0) AS average, COALESCE(ms.rating_count, 0) AS votes) rs`
+
+// ratingColumns selects the average rating, the weighted rating and the number of votes from ratingStatsQuery.
+// The weighted rating is IMDb style, (v / (v + m)) * R + (m / (v + m)) * C where C is the mean of all
+// ratings, so a handful of votes can't outrank a movie many people rated. Both are null for unrated movies
+var ratingColumns = fmt.Sprintf(`TRUNC(rs.average::numeric, 1) AS rating,
+	CASE WHEN rs.votes > 0 THEN TRUNC((rs.votes::float8 / (rs.votes + %[1]d) * rs.average +
+		%[1]d::float8 / (rs.votes + %[1]d) * (SELECT SUM(rating_sum) / SUM(rating_count) FROM movie_stats))::numeric, 2) END AS weighted_rating,
+	rs.votes AS vote_count`, RatingMinVotes)
+
+// setRating sets the rating columns selected by ratingColumns
+func setRating(movie *Movie, rating, weighted sql.NullFloat64) {
+	if rating.Valid {
+		movie.Rating = &rating.Float64
+	}
+	if weighted.Valid {
+		movie.WeightedRating = &weighted.Float64
+	}
+}
+
+func (m *DBModel) GetAllMovies(findByName string, limit *CertificationLimit) ([]*Movie, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var dbArgs []interface{}
-	where := " WHERE title ILIKE $1 OR description ILIKE $2"
+	where := " WHERE (title ILIKE $1 OR description ILIKE $2)"
 	dbArgs = append(dbArgs, "%"+findByName+"%", "%"+findByName+"%")
+	where += " AND " + certificationAllowed("m.id", limit, &dbArgs)
 
-	q2 := ` LEFT JOIN ratings r ON (r.movie_id = m.id)`
-	q3 := ` GROUP BY m.id, m.title, m.description, m.year, m.release_date, m.runtime, m.created_at, m.updated_at
-	order by rating desc limit 2 offset 1`
+	q2 := ratingStatsQuery
+	q3 := ` order by weighted_rating desc nulls last limit 2 offset 1`
 
-	query := `SELECT m.id, m.title, m.description, m.year, m.release_date, COALESCE(trunc(AVG(r.rating)::numeric, 1), 1.0) AS rating, m.runtime, m.created_at, m.updated_at 
+	query := `SELECT m.id, m.title, m.description, m.year, m.release_date, ` + ratingColumns + `, m.runtime, m.created_at, m.updated_at 
 	FROM movies m`
 
 	query += q2 + where + q3
@@ -36,13 +65,16 @@ func (m *DBModel) GetAllMovies(findByName string) ([]*Movie, error) {
 	var movies []*Movie
 	for rows.Next() {
 		var movie Movie
+		var rating, weighted sql.NullFloat64
 		err = rows.Scan(
 			&movie.ID,
 			&movie.Title,
 			&movie.Description,
 			&movie.Year,
 			&movie.ReleaseDate,
-			&movie.Rating,
+			&rating,
+			&weighted,
+			&movie.VoteCount,
 			&movie.Runtime,
 			&movie.CreatedAt,
 			&movie.UpdatedAt,
@@ -51,6 +83,7 @@ func (m *DBModel) GetAllMovies(findByName string) ([]*Movie, error) {
 		if err != nil {
 			return nil, err
 		}
+		setRating(&movie, rating, weighted)
 
 		// // get genres, if any
 		// genreQuery := `select
@@ -87,99 +120,54 @@ func (m *DBModel) GetAllMovies(findByName string) ([]*Movie, error) {
 	return movies, nil
 }
 
-// CheckGenre checks if genre exists
-func (m *DBModel) CheckGenre(genreID int) (bool, error) {
+// Check rating
+func (m *DBModel) CheckRating(movieID, userID int) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `select id from genres where id = $1`
+	query := `select id from ratings where movie_id = $1 and user_id = $2`
 
-	var id int
-	err := m.DB.QueryRowContext(ctx, query, genreID).Scan(&id)
-	if err != nil {
-		return false, err
-	}
+	ratingID := 0
 
-	if id <= 0 {
-		return false, errors.New("Genre not found")
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(&ratingID)
+	if err != nil {
+		return 0, errors.New("Rating not found")
 	}
 
-	return true, nil
+	return ratingID, nil
 }
 
-// InsertGenre inserts a new genre into the database
-func (m *DBModel) InsertGenre(genreName string) (int, error) {
+// InsertRating inserts a new rating into the database
+func (m *DBModel) InsertRating(rating *Rating) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `insert into genres (genre_name, created_at, updated_at) values ($1, $2, $3) returning id`
-
-	var id int
-	err := m.DB.QueryRowContext(ctx, query, genreName, time.Now(), time.Now()).Scan(&id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
-	return id, nil
-}
-
-// UpdateGenre updates a genre in the database
-func (m *DBModel) UpdateGenre(id int, genreName string) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	query := `insert into ratings (movie_id, user_id, rating, created_at, updated_at) values ($1, $2, $3, $4, $5) returning id`
 
-	query := `update genres set genre_name = $1, updated_at = $2 where id = $3`
+	var id int
+	err = tx.QueryRowContext(ctx, query, rating.MovieID, rating.UserID, rating.Rating, rating.CreatedAt, rating.UpdatedAt).Scan(&id)
 
-	_, err := m.DB.ExecContext(ctx, query, genreName, time.Now(), id)
 	if err != nil {
-		return id, err
+		return 0, err
 	}
 
-	return id, nil
-}
-
-// DeleteGenre deletes a genre from the database
-func (m *DBModel) DeleteGenre(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	query := `delete from genres where id = $1`
-
-	_, err := m.DB.ExecContext(ctx, query, id)
+	err = updateMovieStats(ctx, tx, rating.MovieID, statsDelta{ratingSum: float64(rating.Rating), ratings: 1})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
-}
-
-// Check rating
-func (m *DBModel) CheckRating(movieID, userID int) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	query := `select id from ratings where movie_id = $1 and user_id = $2`
-
-	ratingID := 0
-
-	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(&ratingID)
+	err = recordActivity(ctx, tx, rating.UserID, ActivityRated, rating.MovieID, id)
 	if err != nil {
-		return 0, errors.New("Rating not found")
+		return 0, err
 	}
 
-	return ratingID, nil
-}
-
-// InsertRating inserts a new rating into the database
-func (m *DBModel) InsertRating(rating *Rating) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	query := `insert into ratings (movie_id, user_id, rating, created_at, updated_at) values ($1, $2, $3, $4, $5) returning id`
-
-	var id int
-	err := m.DB.QueryRowContext(ctx, query, rating.MovieID, rating.UserID, rating.Rating, rating.CreatedAt, rating.UpdatedAt).Scan(&id)
-
+	err = tx.Commit()
 	if err != nil {
 		return 0, err
 	}
@@ -192,87 +180,67 @@ func (m *DBModel) UpdateRating(rating *Rating) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	query := `update ratings set rating = $1, updated_at = $2 where id = $3`
-
-	_, err := m.DB.ExecContext(ctx, query, rating.Rating, rating.UpdatedAt, rating.ID)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return rating.ID, errors.New("Rating not found")
 	}
+	defer tx.Rollback()
 
-	return rating.ID, nil
-}
-
-// GenreByID returns a single genre based on the ID provided
-func (m *DBModel) GenreByID(id int) (Genre, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	query := `select id, genre_name, created_at, updated_at from genres where id = $1`
-
-	var g Genre
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&g.ID,
-		&g.GenreName,
-		&g.CreatedAt,
-		&g.UpdatedAt,
-	)
+	var movieID, userID int
+	var previous float64
+	query := `select movie_id, user_id, rating from ratings where id = $1 for update`
+	err = tx.QueryRowContext(ctx, query, rating.ID).Scan(&movieID, &userID, &previous)
 	if err != nil {
-		return g, err
+		return rating.ID, errors.New("Rating not found")
 	}
 
-	return g, nil
-}
-
-// Get all genres from db
-func (m *DBModel) GenresAll() ([]*Genre, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	query = `update ratings set rating = $1, updated_at = $2 where id = $3`
 
-	query := `select id, genre_name, created_at, updated_at from genres order by genre_name`
+	_, err = tx.ExecContext(ctx, query, rating.Rating, rating.UpdatedAt, rating.ID)
+	if err != nil {
+		return rating.ID, errors.New("Rating not found")
+	}
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	err = updateMovieStats(ctx, tx, movieID, statsDelta{ratingSum: float64(rating.Rating) - previous})
 	if err != nil {
-		return nil, err
+		return rating.ID, errors.New("failed to update the rating")
 	}
-	defer rows.Close()
 
-	var genres []*Genre
+	err = recordActivity(ctx, tx, userID, ActivityRated, movieID, rating.ID)
+	if err != nil {
+		return rating.ID, errors.New("failed to update the rating")
+	}
 
-	for rows.Next() {
-		var g Genre
-		err := rows.Scan(
-			&g.ID,
-			&g.GenreName,
-			&g.CreatedAt,
-			&g.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		genres = append(genres, &g)
+	err = tx.Commit()
+	if err != nil {
+		return rating.ID, errors.New("failed to update the rating")
 	}
 
-	return genres, nil
+	return rating.ID, nil
 }
 
-// GetFeatureMovies fetches the latest 5 featured movies from the database ordered by their update time
-func (m *DBModel) GetFeatureMovies(userID ...int) ([]*Movie, error) {
+// GetFeatureMovies fetches the featured movies from the database, the movies pinned by the admins first, then
+// the trending movies of the default window and then the latest updated ones
+func (m *DBModel) GetFeatureMovies(limit *CertificationLimit, userID ...int) ([]*Movie, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	// Retrieve latest 5 featured movies ordered by update time
+	args := append(trendingArgs(TrendingWindows[DefaultTrendingWindow]), FeaturedMoviesCount)
+
 	query := `
 		SELECT m.id, m.title, m.image, m.description, m.year, m.release_date,
-		COALESCE(TRUNC(AVG(r.rating)::numeric, 1), 1.0) AS rating,
-		m.runtime, m.created_at, m.updated_at
-		FROM movies m
-		LEFT JOIN ratings r ON r.movie_id = m.id
-		GROUP BY m.id
-		ORDER BY m.updated_at DESC
-		LIMIT 5
+		` + ratingColumns + `,
+		m.runtime, m.created_at, m.updated_at,
+		COALESCE(t.score, 0), fm.movie_id IS NOT NULL
+		FROM movies m` + ratingStatsQuery + `
+		LEFT JOIN featured_movies fm ON (fm.movie_id = m.id)
+		LEFT JOIN (` + trendingQuery(1, 2, 3) + `) t ON (t.movie_id = m.id)
+		WHERE ` + certificationAllowed("m.id", limit, &args) + `
+		ORDER BY fm.position ASC NULLS LAST, fm.created_at DESC, t.score DESC NULLS LAST, m.updated_at DESC
+		LIMIT $4
 	`
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -282,6 +250,7 @@ func (m *DBModel) GetFeatureMovies(userID ...int) ([]*Movie, error) {
 	var image sql.NullString
 	for rows.Next() {
 		var movie Movie
+		var rating, weighted sql.NullFloat64
 		err = rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -289,15 +258,20 @@ func (m *DBModel) GetFeatureMovies(userID ...int) ([]*Movie, error) {
 			&movie.Description,
 			&movie.Year,
 			&movie.ReleaseDate,
-			&movie.Rating,
+			&rating,
+			&weighted,
+			&movie.VoteCount,
 			&movie.Runtime,
 			&movie.CreatedAt,
 			&movie.UpdatedAt,
+			&movie.TrendingScore,
+			&movie.Pinned,
 		)
 
 		if err != nil {
 			return nil, err
 		}
+		setRating(&movie, rating, weighted)
 
 		// Check if the Image value is NULL or empty, and if it is, assign a default value
 		if !image.Valid || image.String == "" {
@@ -335,9 +309,15 @@ func (m *DBModel) GetFeatureMovies(userID ...int) ([]*Movie, error) {
 		genreRows.Close()
 
 		if len(userID) > 0 {
-			// check if movie is favorite
-			favoriteQuery := `select id from favorites where movie_id = $1 and user_id = $2`
-			_ = m.DB.QueryRowContext(ctx, favoriteQuery, movie.ID, userID[0]).Scan(&movie.IsFavorite)
+			movie.IsFavorite, err = m.isFavorite(ctx, movie.ID, userID[0])
+			if err != nil {
+				return nil, err
+			}
+
+			movie.UserRating, err = m.userRating(ctx, movie.ID, userID[0])
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		movie.MovieGenre = genres
@@ -360,25 +340,62 @@ func (m *DBModel) GetAllMoviesByFilter(page, perPage int, filter *MovieFilter, u
 	dbArgs = append(dbArgs, "%"+filter.FindByName+"%", "%"+filter.FindByName+"%")
 
 	if filter.FilterByYear > 0 {
-		where += " and year = $3"
 		dbArgs = append(dbArgs, filter.FilterByYear)
+		where += fmt.Sprintf(" and year = $%d", len(dbArgs))
 	}
 
-	if filter.FilterByGenre > 0 && filter.FilterByYear <= 0 {
-		where += " and m.id in (select movie_id from movies_genres where genre_id = $3)"
+	if filter.FilterByGenre > 0 {
+		// the movies of the sub genres belong to the genre as well
 		dbArgs = append(dbArgs, filter.FilterByGenre)
+		where += " and m.id in (select movie_id from movies_genres where genre_id in (" + genreTreeQuery(len(dbArgs)) + "))"
 	}
 
-	if (filter.FilterByGenre > 0) && (filter.FilterByYear > 0) {
-		where += " and m.id in (select movie_id from movies_genres where genre_id = $4)"
-		dbArgs = append(dbArgs, filter.FilterByGenre)
+	if filter.FilterByTag > 0 {
+		dbArgs = append(dbArgs, filter.FilterByTag)
+		where += fmt.Sprintf(" and m.id in (select movie_id from movie_tags where tag_id = $%d)", len(dbArgs))
+	}
+
+	if filter.FilterByStudio > 0 {
+		dbArgs = append(dbArgs, filter.FilterByStudio)
+		where += fmt.Sprintf(" and m.id in (select movie_id from movie_studios where studio_id = $%d)", len(dbArgs))
+	}
+
+	if filter.FilterByLanguage != "" {
+		dbArgs = append(dbArgs, filter.FilterByLanguage)
+		where += fmt.Sprintf(" and m.id in (select movie_id from movie_languages where language = $%d)", len(dbArgs))
+	}
+
+	if filter.FilterByCountry != "" {
+		dbArgs = append(dbArgs, filter.FilterByCountry)
+		where += fmt.Sprintf(" and m.id in (select movie_id from movie_countries where country = $%d)", len(dbArgs))
+	}
+
+	favoritesArg := 0
+	if filter.FavoritesOf > 0 {
+		dbArgs = append(dbArgs, filter.FavoritesOf)
+		favoritesArg = len(dbArgs)
+		where += fmt.Sprintf(" and m.id in (select movie_id from favorites where user_id = $%d)", favoritesArg)
+	}
+
+	if filter.Certification != nil {
+		where += " and " + certificationAllowed("m.id", filter.Certification, &dbArgs)
 	}
 
 	//	add order by query
 	orderByQuery := ""
 	switch filter.OrderBy {
+	case MovieOrderFavorited:
+		// the latest favorite first, it needs the favorites filter
+		orderByQuery = " order by updated_at desc"
+		if favoritesArg > 0 {
+			orderByQuery = fmt.Sprintf(" order by (select f.created_at from favorites f where f.movie_id = m.id and f.user_id = $%d) desc, m.id desc", favoritesArg)
+		}
 	case "rating":
-		orderByQuery = " order by rating desc"
+		orderByQuery = " order by weighted_rating desc nulls last, vote_count desc"
+	case MovieOrderRevenue:
+		orderByQuery = " order by m.revenue desc, m.id desc"
+	case MovieOrderPopular:
+		orderByQuery = " order by " + popularityColumn + " desc, weighted_rating desc nulls last, m.id desc"
 	case "runtime":
 		orderByQuery = " order by runtime desc"
 	case "old":
@@ -397,20 +414,15 @@ func (m *DBModel) GetAllMoviesByFilter(page, perPage int, filter *MovieFilter, u
 		m.description, 
 		m.year, 
 		m.release_date, 
-		COALESCE(TRUNC(AVG(r.rating)::numeric, 1), 1.0) AS rating, 
+		` + ratingColumns + `,
 		m.runtime, 
 		m.created_at, 
 		m.updated_at,
-		COUNT(DISTINCT c.id) AS comments_count,
-		COUNT(DISTINCT f.id) AS favorites_count
+		COALESCE(ms.comment_count, 0) AS comments_count,
+		COALESCE(ms.favorite_count, 0) AS favorites_count,
+		COALESCE(ms.review_count, 0) AS reviews_count
 	FROM 
-		movies m
-		LEFT JOIN ratings r ON r.movie_id = m.id
-		LEFT JOIN comments c ON c.movie_id = m.id
-		LEFT JOIN favorites f ON f.movie_id = m.id`
-
-	groupBYQuery := ` GROUP BY
-			m.id`
+		movies m` + ratingStatsQuery
 
 	// pagination query
 	paginationQuery := fmt.Sprintf(" limit %d offset %d", perPage, offset)
@@ -423,7 +435,7 @@ func (m *DBModel) GetAllMoviesByFilter(page, perPage int, filter *MovieFilter, u
 	}
 
 	// join all the query
-	query += where + groupBYQuery + orderByQuery + paginationQuery
+	query += where + orderByQuery + paginationQuery
 
 	// execute query with context
 	rows, err := m.DB.QueryContext(ctx, query, dbArgs...)
@@ -436,6 +448,7 @@ func (m *DBModel) GetAllMoviesByFilter(page, perPage int, filter *MovieFilter, u
 	var image sql.NullString
 	for rows.Next() {
 		var movie Movie
+		var rating, weighted sql.NullFloat64
 		err = rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -443,17 +456,21 @@ func (m *DBModel) GetAllMoviesByFilter(page, perPage int, filter *MovieFilter, u
 			&movie.Description,
 			&movie.Year,
 			&movie.ReleaseDate,
-			&movie.Rating,
+			&rating,
+			&weighted,
+			&movie.VoteCount,
 			&movie.Runtime,
 			&movie.CreatedAt,
 			&movie.UpdatedAt,
 			&movie.TotalComments,
 			&movie.TotalFavorites,
+			&movie.TotalReviews,
 		)
 
 		if err != nil {
 			return nil, err
 		}
+		setRating(&movie, rating, weighted)
 
 		// Check if the Image value is NULL or empty, and if it is, assign a default value
 		if !image.Valid || image.String == "" {
@@ -491,13 +508,14 @@ func (m *DBModel) GetAllMoviesByFilter(page, perPage int, filter *MovieFilter, u
 		genreRows.Close()
 
 		if len(userID) > 0 {
-			// check if movie is favorite
-			favoriteQuery := `select id from favorites where movie_id = $1 and user_id = $2`
-			var favID int
-			_ = m.DB.QueryRowContext(ctx, favoriteQuery, movie.ID, userID[0]).Scan(&favID)
+			movie.IsFavorite, err = m.isFavorite(ctx, movie.ID, userID[0])
+			if err != nil {
+				return nil, err
+			}
 
-			if favID > 0 {
-				movie.IsFavorite = true
+			movie.UserRating, err = m.userRating(ctx, movie.ID, userID[0])
+			if err != nil {
+				return nil, err
 			}
 		}
 
@@ -516,28 +534,17 @@ func (m *DBModel) GetAllMoviesByFilter(page, perPage int, filter *MovieFilter, u
 	return paginatedMovies, nil
 }
 
-// InsertMovie is help to insert new movie to the database
-func (m *DBModel) InsertMovie(movie *Movie) (int, map[int]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	movieID := 0
+// resolveMovieGenres returns the genres of a movie by id from their names, the default genre when it has none
+func resolveMovieGenres(ctx context.Context, tx *sql.Tx, names map[int]string) (map[int]string, error) {
 	var movieGenres = make(map[int]string)
 
-	// return if movie title is already exist
-	q := `select id from movies where title = $1`
-	_ = m.DB.QueryRowContext(ctx, q, movie.Title).Scan(&movieID)
-	if movieID > 0 {
-		return movieID, movieGenres, errors.New("the movie is already exist")
-	}
-
 	// verify genres
-	for _, val := range movie.MovieGenre {
+	for _, val := range names {
 		genreID := 0
-		query := `select id from genres where genre_name = $1`
-		err := m.DB.QueryRowContext(ctx, query, val).Scan(&genreID)
+		query := `select id from genres where lower(genre_name) = lower($1)`
+		err := tx.QueryRowContext(ctx, query, val).Scan(&genreID)
 		if err != nil {
-			return movieID, movieGenres, errors.New("invalid genre name")
+			return movieGenres, errors.New("invalid genre name")
 		}
 		if genreID > 0 {
 			if _, exists := movieGenres[genreID]; !exists {
@@ -547,103 +554,181 @@ func (m *DBModel) InsertMovie(movie *Movie) (int, map[int]string, error) {
 	}
 
 	if len(movieGenres) <= 0 {
-		movieGenres[10] = "Unknown"
+		genreID, name, err := defaultGenre(ctx, tx)
+		if err != nil {
+			return movieGenres, err
+		}
+		movieGenres[genreID] = name
+	}
+
+	return movieGenres, nil
+}
+
+// saveMovieGenres replaces the genres of a movie in tx
+func saveMovieGenres(ctx context.Context, tx *sql.Tx, movieID int, movieGenres map[int]string) error {
+	_, err := tx.ExecContext(ctx, `delete from movies_genres where movie_id = $1`, movieID)
+	if err != nil {
+		return err
+	}
+
+	for key := range movieGenres {
+		stmt := `insert into movies_genres ( genre_id, movie_id, created_at, updated_at)
+						values($1, $2, $3, $4)`
+		_, err = tx.ExecContext(ctx, stmt, key, movieID, time.Now(), time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InsertMovie is help to insert new movie to the database with its genres, languages, countries and studios
+func (m *DBModel) InsertMovie(movie *Movie) (int, map[int]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	movieID := 0
+
+	// return if movie title is already exist
+	q := `select id from movies where title = $1`
+	_ = m.DB.QueryRowContext(ctx, q, movie.Title).Scan(&movieID)
+	if movieID > 0 {
+		return movieID, map[int]string{}, errors.New("the movie is already exist")
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return movieID, map[int]string{}, errors.New("invalid movie data! failed to save the movie")
+	}
+	defer tx.Rollback()
+
+	movieGenres, err := resolveMovieGenres(ctx, tx, movie.MovieGenre)
+	if err != nil {
+		return movieID, movieGenres, err
+	}
+
+	err = checkExternalIDs(ctx, tx, movie)
+	if err != nil {
+		return movieID, movieGenres, err
 	}
 
 	stmt := `insert into movies (title, description, year, release_date, runtime, image,
-		created_at, updated_at) values ($1, $2, $3, $4, $5, $6, $7, $8)
+		original_title, tagline, budget, revenue, imdb_id, tmdb_id,
+		created_at, updated_at) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		RETURNING id`
 
-	err := m.DB.QueryRowContext(ctx, stmt,
+	err = tx.QueryRowContext(ctx, stmt,
 		movie.Title,
 		movie.Description,
 		movie.Year,
 		movie.ReleaseDate,
 		movie.Runtime,
 		movie.Image,
+		movie.OriginalTitle,
+		movie.Tagline,
+		movie.Budget,
+		movie.Revenue,
+		sql.NullString{String: movie.IMDbID, Valid: movie.IMDbID != ""},
+		nullInt(movie.TMDbID),
 		time.Now(),
 		time.Now(),
 	).Scan(&movieID)
 	if err != nil {
+		if taken := externalIDTaken(err); taken != nil {
+			return 0, movieGenres, taken
+		}
 		log.Println(err)
-		return movieID, movieGenres, err
-		// return movieID, movieGenres, errors.New("invalid movie data! failed to save the movie")
+		return 0, movieGenres, errors.New("invalid movie data! failed to save the movie")
 	}
 
-	for key := range movieGenres {
-		stmt = `insert into movies_genres ( genre_id, movie_id, created_at, updated_at)
-						values($1, $2, $3, $4)`
-		_, err = m.DB.ExecContext(ctx, stmt, key, movieID, time.Now(), time.Now())
-		if err != nil {
-			return movieID, movieGenres, err
-		}
+	err = saveMovieGenres(ctx, tx, movieID, movieGenres)
+	if err != nil {
+		return 0, movieGenres, errors.New("failed to save the movie genres")
 	}
+
+	err = setMovieMetadata(ctx, tx, movieID, movie)
+	if err != nil {
+		return 0, movieGenres, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, movieGenres, errors.New("invalid movie data! failed to save the movie")
+	}
+
 	return movieID, movieGenres, nil
 }
 
-// UpdateMovie is help to update a movie from the database
+// UpdateMovie is help to update a movie from the database with its genres, languages, countries and studios
 func (m *DBModel) UpdateMovie(movie *Movie) (int, map[int]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	movieID := 0
-	var movieGenres = make(map[int]string)
 
-	// verify genres
-	for _, val := range movie.MovieGenre {
-		genreID := 0
-		query := `select id from genres where genre_name = $1`
-		err := m.DB.QueryRowContext(ctx, query, val).Scan(&genreID)
-		if err != nil {
-			return movieID, movieGenres, errors.New("invalid genre name")
-		}
-		if genreID > 0 {
-			if _, exists := movieGenres[genreID]; !exists {
-				movieGenres[genreID] = val
-			}
-		}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return movieID, map[int]string{}, errors.New("invalid movie data! failed to update the movie")
 	}
+	defer tx.Rollback()
 
-	if len(movieGenres) <= 0 {
-		movieGenres[10] = "Unknown"
+	movieGenres, err := resolveMovieGenres(ctx, tx, movie.MovieGenre)
+	if err != nil {
+		return movieID, movieGenres, err
+	}
+
+	err = checkExternalIDs(ctx, tx, movie)
+	if err != nil {
+		return movieID, movieGenres, err
 	}
 
 	stmt := `update movies set title = $1, description = $2, year = $3, release_date = $4, 
 	runtime = $5,
 	image = $6,
-	updated_at = $7 where id = $8
+	original_title = $7, tagline = $8, budget = $9, revenue = $10, imdb_id = $11, tmdb_id = $12,
+	updated_at = $13 where id = $14
 	RETURNING id`
 
-	err := m.DB.QueryRowContext(ctx, stmt,
+	err = tx.QueryRowContext(ctx, stmt,
 		movie.Title,
 		movie.Description,
 		movie.Year,
 		movie.ReleaseDate,
 		movie.Runtime,
 		movie.Image,
+		movie.OriginalTitle,
+		movie.Tagline,
+		movie.Budget,
+		movie.Revenue,
+		sql.NullString{String: movie.IMDbID, Valid: movie.IMDbID != ""},
+		nullInt(movie.TMDbID),
 		time.Now(),
 		movie.ID,
 	).Scan(&movieID)
 	if err != nil {
+		if taken := externalIDTaken(err); taken != nil {
+			return movieID, movieGenres, taken
+		}
 		log.Println(err)
 		return movieID, movieGenres, errors.New("invalid movie data! failed to update the movie")
 	}
 
-	q := `delete from movies_genres where movie_id = $1`
+	err = saveMovieGenres(ctx, tx, movieID, movieGenres)
+	if err != nil {
+		return movieID, movieGenres, errors.New("failed to save the movie genres")
+	}
 
-	_, err = m.DB.ExecContext(ctx, q, movieID)
+	err = setMovieMetadata(ctx, tx, movieID, movie)
 	if err != nil {
-		return movieID, movieGenres, errors.New("something went wrong")
+		return movieID, movieGenres, err
 	}
 
-	for key := range movieGenres {
-		stmt = `insert into movies_genres ( genre_id, movie_id, created_at, updated_at)
-						values($1, $2, $3, $4)`
-		_, err = m.DB.ExecContext(ctx, stmt, key, movieID, time.Now(), time.Now())
-		if err != nil {
-			return movieID, movieGenres, errors.New("failed to save the movie genres")
-		}
+	err = tx.Commit()
+	if err != nil {
+		return movieID, movieGenres, errors.New("invalid movie data! failed to update the movie")
 	}
+
 	return movieID, movieGenres, nil
 }
 
@@ -653,17 +738,16 @@ func (m *DBModel) Get(id int) (*Movie, error) {
 	defer cancel()
 
 	query := `SELECT m.id, m.title, m.description, m.year, m.release_date, m.runtime, m.image, m.created_at, m.updated_at,
-    COALESCE(TRUNC(AVG(r.rating)::numeric, 1), 1.0) AS rating
-FROM movies m
-LEFT JOIN ratings r ON r.movie_id = m.id
-WHERE m.id = $1
-GROUP BY m.id;
+    ` + ratingColumns + `
+FROM movies m` + ratingStatsQuery + `
+WHERE m.id = $1;
 `
 
 	row := m.DB.QueryRowContext(ctx, query, id)
 
 	var movie Movie
 	var image sql.NullString
+	var rating, weighted sql.NullFloat64
 
 	err := row.Scan(
 		&movie.ID,
@@ -675,11 +759,14 @@ GROUP BY m.id;
 		&image,
 		&movie.CreatedAt,
 		&movie.UpdatedAt,
-		&movie.Rating,
+		&rating,
+		&weighted,
+		&movie.VoteCount,
 	)
 	if err != nil {
 		return nil, err
 	}
+	setRating(&movie, rating, weighted)
 
 	// Check if the Image value is NULL or empty, and if it is, assign a default value
 	if !image.Valid || image.String == "" {
@@ -718,39 +805,19 @@ where
 
 	movie.MovieGenre = genres
 
-	// Get comments ordered by recent update
-	query = `SELECT
-    c.id, c.user_id, c.comment, c.created_at, c.updated_at, u.name
-    FROM
-    	comments c
-    LEFT JOIN users u ON (u.id = c.user_id)
-    WHERE
-    	c.movie_id = $1
-  	ORDER BY c.created_at DESC
-    `
-
-	rows, _ := m.DB.QueryContext(ctx, query, id)
-	defer rows.Close()
-
-	var comments []Comment
-	for rows.Next() {
-		var comment Comment
-		err := rows.Scan(
-			&comment.ID,
-			&comment.UserID,
-			&comment.Comment,
-			&comment.CreatedAt,
-			&comment.UpdatedAt,
-			&comment.UserName,
-		)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, comment)
+	// Get a preview of the latest top level comments, the rest are paginated separately
+	comments, err := m.listComments(ctx, &CommentFilter{MovieID: id, SortBy: CommentSortNewest, Limit: CommentsPreviewSize})
+	if err != nil {
+		return nil, err
 	}
 
-	movie.Comments = comments
-	movie.TotalComments = len(comments)
+	movie.Comments = comments.Comments
+
+	query = `select count(c.id) from comments c where c.movie_id = $1 and not ` + removedComment("c", "0", "$2")
+	err = m.DB.QueryRowContext(ctx, query, id, time.Now()).Scan(&movie.TotalComments)
+	if err != nil {
+		return nil, err
+	}
 
 	return &movie, nil
 }
@@ -775,19 +842,21 @@ func (m *DBModel) GetOneMovie(id, userID int) (*Movie, error) {
 	defer cancel()
 
 	query := `SELECT m.id, m.title, m.description, m.year, m.release_date, m.runtime, m.image, m.created_at, m.updated_at,
-    COALESCE(TRUNC(AVG(r.rating)::numeric, 1), 1.0) AS rating,
-		COUNT(DISTINCT f.id) AS favorites_count
-FROM movies m
-LEFT JOIN ratings r ON r.movie_id = m.id
-LEFT JOIN favorites f ON f.movie_id = m.id
-WHERE m.id = $1
-GROUP BY m.id;
+    ` + ratingColumns + `,
+		m.original_title, m.tagline, m.budget, m.revenue, m.imdb_id, m.tmdb_id,
+		COALESCE(ms.favorite_count, 0) AS favorites_count,
+		COALESCE(ms.review_count, 0) AS reviews_count
+FROM movies m` + ratingStatsQuery + `
+WHERE m.id = $1;
 `
 
 	row := m.DB.QueryRowContext(ctx, query, id)
 
 	var movie Movie
 	var image sql.NullString
+	var rating, weighted sql.NullFloat64
+	var imdbID sql.NullString
+	var tmdbID sql.NullInt64
 
 	err := row.Scan(
 		&movie.ID,
@@ -799,12 +868,24 @@ GROUP BY m.id;
 		&image,
 		&movie.CreatedAt,
 		&movie.UpdatedAt,
-		&movie.Rating,
+		&rating,
+		&weighted,
+		&movie.VoteCount,
+		&movie.OriginalTitle,
+		&movie.Tagline,
+		&movie.Budget,
+		&movie.Revenue,
+		&imdbID,
+		&tmdbID,
 		&movie.TotalFavorites,
+		&movie.TotalReviews,
 	)
 	if err != nil {
 		return nil, err
 	}
+	setRating(&movie, rating, weighted)
+	movie.IMDbID = imdbID.String
+	movie.TMDbID = int(tmdbID.Int64)
 
 	// Check if the Image value is NULL or empty, and if it is, assign a default value
 	if !image.Valid || image.String == "" {
@@ -843,156 +924,71 @@ where
 
 	movie.MovieGenre = genres
 
-	// Get comments ordered by recent update
-	query = `SELECT
-    c.id, c.user_id, c.comment, c.created_at, c.updated_at, u.name
-    FROM
-    	comments c
-    LEFT JOIN users u ON (u.id = c.user_id)
-    WHERE
-    	c.movie_id = $1
-  	ORDER BY c.created_at DESC
-    `
-
-	rows, _ := m.DB.QueryContext(ctx, query, id)
-	defer rows.Close()
-
-	var comments []Comment
-	for rows.Next() {
-		var comment Comment
-		err := rows.Scan(
-			&comment.ID,
-			&comment.UserID,
-			&comment.Comment,
-			&comment.CreatedAt,
-			&comment.UpdatedAt,
-			&comment.UserName,
-		)
-		if err != nil {
-			return nil, err
-		}
-		comments = append(comments, comment)
+	// Get a preview of the latest top level comments, the rest are paginated separately
+	comments, err := m.listComments(ctx, &CommentFilter{MovieID: id, UserID: userID, SortBy: CommentSortNewest, Limit: CommentsPreviewSize})
+	if err != nil {
+		return nil, err
 	}
 
-	movie.Comments = comments
-	movie.TotalComments = len(comments)
+	movie.Comments = comments.Comments
 
-	if userID > 0 {
-		// check if movie is favorite
-		favoriteQuery := `select id from favorites where movie_id = $1 and user_id = $2`
-		var favID int
-		_ = m.DB.QueryRowContext(ctx, favoriteQuery, id, userID).Scan(&favID)
-
-		if favID > 0 {
-			movie.IsFavorite = true
-		}
+	query = `select count(c.id) from comments c where c.movie_id = $1 and not ` + removedComment("c", "$2", "$3")
+	err = m.DB.QueryRowContext(ctx, query, id, userID, time.Now()).Scan(&movie.TotalComments)
+	if err != nil {
+		return nil, err
 	}
 
-	return &movie, nil
-}
-
-// CheckComment returns comment_id and error, if any
-func (m *DBModel) CheckComment(commentID int) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	var id int
-	query := `select id from comments where id = $1`
-
-	err := m.DB.QueryRowContext(ctx, query, commentID).Scan(&id)
+	movie.RatingHistogram, err = m.ratingHistogram(ctx, id)
 	if err != nil {
-		return id, errors.New("invalid comment id")
+		return nil, err
 	}
 
-	return id, nil
-}
-
-// Get Comment returns one comment and error, if any
-func (m *DBModel) GetComment(id int) (*Comment, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	query := `select id, movie_id, user_id, comment, created_at, updated_at from comments where id = $1`
-
-	row := m.DB.QueryRowContext(ctx, query, id)
-
-	var comment Comment
-
-	err := row.Scan(
-		&comment.ID,
-		&comment.MovieID,
-		&comment.UserID,
-		&comment.Comment,
-		&comment.CreatedAt,
-		&comment.UpdatedAt,
-	)
+	movie.Tags, err = m.movieTags(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return &comment, nil
-}
-
-// InsertComment is help to add a comment to the database
-func (m *DBModel) InsertComment(comment *Comment) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	var commentID int
-	stmt := `insert into comments (movie_id, user_id, comment, created_at, updated_at)
-						values($1, $2, $3, $4, $5)
-						RETURNING id`
-
-	err := m.DB.QueryRowContext(ctx, stmt,
-		comment.MovieID,
-		comment.UserID,
-		comment.Comment,
-		time.Now(),
-		time.Now(),
-	).Scan(&commentID)
+	movie.Certifications, err = m.movieCertifications(ctx, id)
 	if err != nil {
-		return commentID, errors.New("failed to add the comment")
+		return nil, err
 	}
 
-	return commentID, nil
-}
-
-// UpdateComment is help to edit a comment
-func (m *DBModel) UpdateComment(comment *Comment) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	var commentID int
-	stmt := `update comments set comment = $1, updated_at = $2 where id = $3
-	RETURNING id`
-
-	err := m.DB.QueryRowContext(ctx, stmt,
-		comment.Comment,
-		time.Now(),
-		comment.ID,
-	).Scan(&commentID)
+	err = m.movieMetadata(ctx, &movie)
 	if err != nil {
-		return commentID, errors.New("failed to update the comment")
+		return nil, err
 	}
 
-	return commentID, nil
-}
-
-// DeleteComment is help to delete a comment
-func (m *DBModel) DeleteComment(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	if userID > 0 {
+		movie.IsFavorite, err = m.isFavorite(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
 
-	stmt := "delete from comments where id = $1"
+		movie.UserRating, err = m.userRating(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
 
-	_, err := m.DB.ExecContext(ctx, stmt, id)
-	if err != nil {
-		return errors.New("failed to delete the comment")
+		movie.InWatchlist, err = m.inWatchlist(ctx, id, userID)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return &movie, nil
+}
+
+// isFavorite tells whether the movie is a favorite of the user
+func (m *DBModel) isFavorite(ctx context.Context, movieID, userID int) (bool, error) {
+	var favorite bool
+	query := `select exists (select 1 from favorites where movie_id = $1 and user_id = $2)`
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(&favorite)
+	return favorite, err
 }
 
+// ErrFavoriteNotFound is returned when the movie is not a favorite of the user
+var ErrFavoriteNotFound = errors.New("Favorite does not found")
+
 // FindFavorites is helps to find any favorite is exist base on movie_id and user_id
 func (m *DBModel) FindFavorites(userID, movieID int) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -1003,44 +999,102 @@ func (m *DBModel) FindFavorites(userID, movieID int) (int, error) {
 	favID := 0
 
 	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(&favID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrFavoriteNotFound
+	}
 	if err != nil {
-		return 0, errors.New("Favorite does not found")
+		return 0, err
 	}
 
 	return favID, nil
 }
 
-// AddFavorite is help to add a favorite movie to the database
+// AddFavorite is help to add a favorite movie to the database, a movie already in the favorites of the user
+// keeps its first favorite
 func (m *DBModel) AddFavorite(favorite *Favorite) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to add the favorite")
+	}
+	defer tx.Rollback()
+
 	var favoriteID int
 	stmt := `insert into favorites (user_id, movie_id, created_at, updated_at)
 						values($1, $2, $3, $4)
+						on conflict (user_id, movie_id) do nothing
 						RETURNING id`
 
-	err := m.DB.QueryRowContext(ctx, stmt,
+	err = tx.QueryRowContext(ctx, stmt,
 		favorite.UserID,
 		favorite.MovieID,
 		time.Now(),
 		time.Now(),
 	).Scan(&favoriteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return m.FindFavorites(favorite.UserID, favorite.MovieID)
+	}
 	if err != nil {
 		return favoriteID, errors.New("failed to add the favorite")
 	}
 
+	err = updateMovieStats(ctx, tx, favorite.MovieID, statsDelta{favorites: 1})
+	if err != nil {
+		return 0, errors.New("failed to add the favorite")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to add the favorite")
+	}
+
 	return favoriteID, nil
 }
 
-// DeleteComment is help to delete a comment
+// RemoveUserFavorite is help to remove a movie from the favorites of the user, removing a movie that is not
+// a favorite does nothing
+func (m *DBModel) RemoveUserFavorite(userID, movieID int) error {
+	favID, err := m.FindFavorites(userID, movieID)
+	if errors.Is(err, ErrFavoriteNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("failed to remove favorite")
+	}
+
+	return m.RemoveFavorite(favID)
+}
+
+// RemoveFavorite is help to remove a favorite
 func (m *DBModel) RemoveFavorite(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	stmt := "delete from favorites where id = $1"
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to remove favorite")
+	}
+	defer tx.Rollback()
 
-	_, err := m.DB.ExecContext(ctx, stmt, id)
+	var movieID int
+	stmt := "delete from favorites where id = $1 returning movie_id"
+
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&movieID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return errors.New("failed to remove favorite")
+	}
+
+	err = updateMovieStats(ctx, tx, movieID, statsDelta{favorites: -1})
+	if err != nil {
+		return errors.New("failed to remove favorite")
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return errors.New("failed to remove favorite")
 	}
@@ -1174,3 +1228,34 @@ func (m *DBModel) DeleteImage(image *Image) error {
 
 // 	return nil
 // }
+
+// ratingHistogram counts the votes of a movie in buckets of one point, a rating of 7.5 falls in the 7 bucket
+func (m *DBModel) ratingHistogram(ctx context.Context, movieID int) ([]RatingBucket, error) {
+	query := `select b.bucket, count(r.id)
+	from generate_series(1, 10) as b(bucket)
+	left join ratings r on (r.movie_id = $1 and least(floor(r.rating), 10) = b.bucket)
+	group by b.bucket
+	order by b.bucket`
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histogram := []RatingBucket{}
+	for rows.Next() {
+		var bucket RatingBucket
+		err := rows.Scan(&bucket.Rating, &bucket.Count)
+		if err != nil {
+			return nil, err
+		}
+		histogram = append(histogram, bucket)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return histogram, nil
+}
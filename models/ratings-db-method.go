@@ -0,0 +1,158 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sort options for the ratings of a user
+const (
+	RatingSortDate  = "date"
+	RatingSortScore = "score"
+)
+
+var (
+	// ErrRatingNotFound is returned when the user has not rated the movie
+	ErrRatingNotFound = errors.New("rating not found")
+	// ErrRatingHasReview is returned when a rating is removed while the user still has a review of the movie
+	ErrRatingHasReview = errors.New("delete your review of the movie before removing the rating")
+)
+
+// DeleteRating removes the rating of a user for a movie, a rating backing a review can't be removed
+func (m *DBModel) DeleteRating(movieID, userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to delete the rating")
+	}
+	defer tx.Rollback()
+
+	// lock the rating so a review can't be written against it meanwhile
+	var id int
+	var rating float64
+	query := `select id, rating from ratings where movie_id = $1 and user_id = $2 for update`
+	err = tx.QueryRowContext(ctx, query, movieID, userID).Scan(&id, &rating)
+	if err == sql.ErrNoRows {
+		return 0, ErrRatingNotFound
+	}
+	if err != nil {
+		return 0, errors.New("failed to delete the rating")
+	}
+
+	var hasReview bool
+	query = `select exists(select 1 from reviews where movie_id = $1 and user_id = $2)`
+	err = tx.QueryRowContext(ctx, query, movieID, userID).Scan(&hasReview)
+	if err != nil {
+		return 0, errors.New("failed to delete the rating")
+	}
+
+	if hasReview {
+		return 0, ErrRatingHasReview
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from ratings where id = $1`, id)
+	if err != nil {
+		return 0, errors.New("failed to delete the rating")
+	}
+
+	err = updateMovieStats(ctx, tx, movieID, statsDelta{ratingSum: -rating, ratings: -1})
+	if err != nil {
+		return 0, errors.New("failed to delete the rating")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to delete the rating")
+	}
+
+	return id, nil
+}
+
+// GetUserRatings returns a page of the movies rated by a user, sorted by date or by score
+func (m *DBModel) GetUserRatings(userID, page, perPage int, sortBy string) (*PaginatedRatings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	//	add order by query
+	orderByQuery := ""
+	switch sortBy {
+	case RatingSortScore:
+		orderByQuery = " ORDER BY r.rating DESC, r.updated_at DESC, r.id DESC"
+	default:
+		orderByQuery = " ORDER BY r.updated_at DESC, r.id DESC"
+	}
+
+	var totalCount int
+	countQuery := `SELECT COUNT(id) FROM ratings WHERE user_id = $1`
+	err := m.DB.QueryRowContext(ctx, countQuery, userID).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT r.id, r.movie_id, m.title, r.user_id, r.rating, r.created_at, r.updated_at
+	FROM
+		ratings r
+		JOIN movies m ON (m.id = r.movie_id)
+	WHERE r.user_id = $1` + orderByQuery + fmt.Sprintf(" LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []*Rating{}
+	for rows.Next() {
+		var rating Rating
+		err = rows.Scan(
+			&rating.ID,
+			&rating.MovieID,
+			&rating.MovieTitle,
+			&rating.UserID,
+			&rating.Rating,
+			&rating.CreatedAt,
+			&rating.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, &rating)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedRatings{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Ratings:     ratings,
+	}, nil
+}
+
+// userRating returns the rating a user gave to a movie, nil when they have not rated it
+func (m *DBModel) userRating(ctx context.Context, movieID, userID int) (*float32, error) {
+	if userID <= 0 {
+		return nil, nil
+	}
+
+	var rating float32
+	query := `select rating from ratings where movie_id = $1 and user_id = $2`
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(&rating)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rating, nil
+}
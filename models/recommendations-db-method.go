@@ -0,0 +1,266 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/raihan2bd/filmwise/recommend"
+)
+
+// settings of the item-item model
+const (
+	RecommendationNeighbors = 50 // similar movies kept for every movie
+	RecommendationMinCommon = 3  // users who must have rated both movies of a pair
+)
+
+// sources of a recommendation
+const (
+	RecommendationFromRatings = "similar_ratings"            // the item-item model
+	RecommendationFromGenres  = "popular_in_favorite_genres" // cold start, the user has too few ratings
+	RecommendationPopular     = "popular"                    // cold start, nothing is known about the user
+)
+
+// TrainItemSimilarities trains the item-item model from the ratings table and replaces item_similarities with
+// it. It returns the number of rows written
+func (m *DBModel) TrainItemSimilarities() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT user_id, movie_id, rating FROM ratings`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ratings []recommend.Rating
+	for rows.Next() {
+		var r recommend.Rating
+		err = rows.Scan(&r.UserID, &r.MovieID, &r.Score)
+		if err != nil {
+			return 0, err
+		}
+		ratings = append(ratings, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	model := recommend.TrainItemItem(ratings, RecommendationNeighbors, RecommendationMinCommon)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `delete from item_similarities`)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `insert into item_similarities (movie_id, similar_id, similarity, trained_at) values($1, $2, $3, $4)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	count := 0
+	for movieID, neighbors := range model {
+		for _, n := range neighbors {
+			_, err = stmt.ExecContext(ctx, movieID, n.ID, n.Similarity, now)
+			if err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetRecommendations returns up to limit movies for the user from the item-item model. When the model has too
+// little to go on, the list is filled with popular movies in the genres the user likes, and then with popular
+// movies. Movies the user rated or above the certification limit are never recommended
+func (m *DBModel) GetRecommendations(userID, limit int, cert *CertificationLimit) ([]*Recommendation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var mean float64
+	err := m.DB.QueryRowContext(ctx, `SELECT COALESCE(AVG(rating), 0) FROM ratings WHERE user_id = $1`, userID).Scan(&mean)
+	if err != nil {
+		return nil, err
+	}
+
+	// the neighbors of the movies the user rated that they have not rated yet
+	args := []interface{}{userID}
+	query := `SELECT s.similar_id, s.similarity, r.rating
+	FROM
+		ratings r
+		JOIN item_similarities s ON (s.movie_id = r.movie_id)
+	WHERE r.user_id = $1
+		AND s.similar_id NOT IN (SELECT movie_id FROM ratings WHERE user_id = $1)
+		AND ` + certificationAllowed("s.similar_id", cert, &args)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var evidence []recommend.Evidence
+	for rows.Next() {
+		var e recommend.Evidence
+		err = rows.Scan(&e.CandidateID, &e.Similarity, &e.Score)
+		if err != nil {
+			return nil, err
+		}
+		evidence = append(evidence, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	sources := make(map[int]string)
+	for _, s := range recommend.Predict(evidence, mean, limit) {
+		ids = append(ids, s.ID)
+		sources[s.ID] = RecommendationFromRatings
+	}
+
+	// cold start, fill the list with popular movies
+	for _, source := range []string{RecommendationFromGenres, RecommendationPopular} {
+		if len(ids) >= limit {
+			break
+		}
+
+		popular, err := m.popularUnrated(ctx, userID, source == RecommendationFromGenres, limit+len(ids), cert)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range popular {
+			if len(ids) >= limit {
+				break
+			}
+			if _, ok := sources[id]; !ok {
+				ids = append(ids, id)
+				sources[id] = source
+			}
+		}
+	}
+
+	movies, err := m.moviesByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := []*Recommendation{}
+	for _, id := range ids {
+		if movie, ok := movies[id]; ok {
+			recommendations = append(recommendations, &Recommendation{Movie: movie, Source: sources[id]})
+		}
+	}
+
+	return recommendations, nil
+}
+
+// popularUnrated returns the best rated movies the user has not rated, only in the genres of the movies they
+// favorited or rated 7 or more when inFavoriteGenres is set
+func (m *DBModel) popularUnrated(ctx context.Context, userID int, inFavoriteGenres bool, limit int, cert *CertificationLimit) ([]int, error) {
+	args := []interface{}{userID, limit}
+	where := ` WHERE m.id NOT IN (SELECT movie_id FROM ratings WHERE user_id = $1)
+		AND ` + certificationAllowed("m.id", cert, &args)
+	if inFavoriteGenres {
+		where += ` AND m.id IN (SELECT movie_id FROM movies_genres WHERE genre_id IN (
+			SELECT mg.genre_id FROM movies_genres mg WHERE mg.movie_id IN (
+				SELECT movie_id FROM favorites WHERE user_id = $1
+				UNION
+				SELECT movie_id FROM ratings WHERE user_id = $1 AND rating >= 7
+			)
+		))`
+	}
+
+	query := `SELECT m.id, ` + ratingColumns + ` FROM movies m` + ratingStatsQuery + where + `
+	ORDER BY weighted_rating DESC NULLS LAST, vote_count DESC, m.id DESC
+	LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id, votes int
+		var rating, weighted sql.NullFloat64
+		err = rows.Scan(&id, &rating, &weighted, &votes)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// moviesByID returns the movies with the given ids for a listing, keyed by id
+func (m *DBModel) moviesByID(ctx context.Context, ids []int) (map[int]*Movie, error) {
+	movies := make(map[int]*Movie, len(ids))
+	if len(ids) == 0 {
+		return movies, nil
+	}
+
+	query := `SELECT m.id, m.title, m.image, m.description, m.year, m.release_date,
+		` + ratingColumns + `,
+		m.runtime
+	FROM movies m` + ratingStatsQuery + `
+	WHERE m.id = ANY($1)`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie Movie
+		var image sql.NullString
+		var rating, weighted sql.NullFloat64
+		err = rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&image,
+			&movie.Description,
+			&movie.Year,
+			&movie.ReleaseDate,
+			&rating,
+			&weighted,
+			&movie.VoteCount,
+			&movie.Runtime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		setRating(&movie, rating, weighted)
+		movie.Image = movieImage(image)
+
+		movies[movie.ID] = &movie
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/raihan2bd/filmwise/recommend"
+)
+
+// RelatedMoviesPerMovie is the number of related movies kept for every movie
+const RelatedMoviesPerMovie = 50
+
+// recommendationItems reads what the scoring needs to know about every movie
+func (m *DBModel) recommendationItems(ctx context.Context) ([]recommend.Item, error) {
+	query := `SELECT m.id, m.year, ` + ratingColumns + ` FROM movies m` + ratingStatsQuery
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []recommend.Item
+	index := make(map[int]int)
+	for rows.Next() {
+		var item recommend.Item
+		var rating, weighted sql.NullFloat64
+		var votes int
+		err = rows.Scan(&item.ID, &item.Year, &rating, &weighted, &votes)
+		if err != nil {
+			return nil, err
+		}
+		item.Rating = weighted.Float64
+
+		index[item.ID] = len(items)
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	genreRows, err := m.DB.QueryContext(ctx, `SELECT movie_id, genre_id FROM movies_genres`)
+	if err != nil {
+		return nil, err
+	}
+	defer genreRows.Close()
+
+	for genreRows.Next() {
+		var movieID, genreID int
+		err = genreRows.Scan(&movieID, &genreID)
+		if err != nil {
+			return nil, err
+		}
+
+		if i, ok := index[movieID]; ok {
+			items[i].Genres = append(items[i].Genres, genreID)
+		}
+	}
+
+	if err = genreRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// RefreshRelatedMovies scores every pair of movies and replaces the related_movies table with the best
+// RelatedMoviesPerMovie of each movie. It returns the number of rows written
+func (m *DBModel) RefreshRelatedMovies(w recommend.Weights) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	items, err := m.recommendationItems(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	related := recommend.Related(items, w, RelatedMoviesPerMovie)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `delete from related_movies`)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `insert into related_movies (movie_id, related_id, score, computed_at) values($1, $2, $3, $4)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	count := 0
+	for movieID, scored := range related {
+		for _, s := range scored {
+			_, err = stmt.ExecContext(ctx, movieID, s.ID, s.Score, now)
+			if err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetRelatedMovies returns up to limit movies like the given one, the most related first
+func (m *DBModel) GetRelatedMovies(movieID, limit int, cert *CertificationLimit) ([]*Movie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{movieID, limit}
+
+	query := `SELECT m.id, m.title, m.image, m.description, m.year, m.release_date,
+		` + ratingColumns + `,
+		m.runtime
+	FROM
+		related_movies rm
+		JOIN movies m ON (m.id = rm.related_id)` + ratingStatsQuery + `
+	WHERE rm.movie_id = $1 AND ` + certificationAllowed("m.id", cert, &args) + `
+	ORDER BY rm.score DESC, m.id ASC
+	LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		var image sql.NullString
+		var rating, weighted sql.NullFloat64
+		err = rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&image,
+			&movie.Description,
+			&movie.Year,
+			&movie.ReleaseDate,
+			&rating,
+			&weighted,
+			&movie.VoteCount,
+			&movie.Runtime,
+		)
+		if err != nil {
+			return nil, err
+		}
+		setRating(&movie, rating, weighted)
+		movie.Image = movieImage(image)
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
@@ -0,0 +1,656 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// things a user can report
+const (
+	ReportTargetComment = "comment"
+	ReportTargetReview  = "review"
+	ReportTargetProfile = "profile"
+)
+
+// status of a report in the moderation queue
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusClaimed  = "claimed"
+	ReportStatusResolved = "resolved"
+)
+
+// actions a moderator can take to resolve a report
+const (
+	ModerationDismiss = "dismiss"
+	ModerationHide    = "hide"
+	ModerationDelete  = "delete"
+	ModerationWarn    = "warn"
+	ModerationBan     = "ban"
+)
+
+// ReportReasons are the reasons a user can pick when reporting
+var ReportReasons = []string{"spam", "harassment", "hate_speech", "spoiler", "inappropriate", "other"}
+
+// ReportReasonContentFilter is the reason of the reports the content filter makes, they have no reporter
+const ReportReasonContentFilter = "content_filter"
+
+var (
+	ErrDuplicateReport         = errors.New("you have already reported this")
+	ErrReportClaimed           = errors.New("report is claimed by another moderator")
+	ErrReportResolved          = errors.New("report is already resolved")
+	ErrInvalidModerationAction = errors.New("action is not allowed for this report")
+	ErrStaffBan                = errors.New("admins and moderators can't be banned from a report")
+)
+
+// hideableTargets maps the reported things that can be hidden or deleted to their table
+var hideableTargets = map[string]string{
+	ReportTargetComment: "comments",
+	ReportTargetReview:  "reviews",
+}
+
+// countedColumn tells if a hideable target is counted in the statistics of its movie when it is shown,
+// a deleted comment is not
+var countedColumn = map[string]string{
+	ReportTargetComment: "not is_deleted",
+	ReportTargetReview:  "true",
+}
+
+// ModerationActionAllowed tells if a moderator can resolve a report on targetType with action,
+// profiles can't be hidden nor deleted
+func ModerationActionAllowed(targetType, action string) bool {
+	switch action {
+	case ModerationDismiss, ModerationWarn, ModerationBan:
+		return true
+	case ModerationHide, ModerationDelete:
+		_, ok := hideableTargets[targetType]
+		return ok
+	}
+
+	return false
+}
+
+// reportQuery selects a report with the reported content and the number of unresolved reports on it
+const reportQuery = `SELECT
+	rp.id, rp.target_type, rp.target_id, COALESCE(rp.target_user_id, 0),
+	COALESCE(CASE rp.target_type
+		WHEN 'comment' THEN (SELECT c.comment FROM comments c WHERE c.id = rp.target_id)
+		WHEN 'review' THEN (SELECT rv.title || E'\n\n' || rv.body FROM reviews rv WHERE rv.id = rp.target_id)
+		WHEN 'profile' THEN (SELECT u.name FROM users u WHERE u.id = rp.target_id)
+	END, ''),
+	COALESCE(rp.reporter_id, 0), COALESCE(ru.name, ''), rp.reason, COALESCE(rp.note, ''), rp.status,
+	(SELECT COUNT(o.id) FROM reports o
+		WHERE o.target_type = rp.target_type AND o.target_id = rp.target_id AND o.status <> 'resolved'),
+	COALESCE(rp.claimed_by, 0), rp.claimed_at, COALESCE(rp.resolved_by, 0), rp.resolved_at,
+	COALESCE(rp.resolution, ''), rp.created_at
+FROM
+	reports rp
+	LEFT JOIN users ru ON (ru.id = rp.reporter_id)`
+
+// scanReport reads a row selected by reportQuery
+func scanReport(row interface{ Scan(...interface{}) error }) (*Report, error) {
+	var report Report
+	var claimedAt, resolvedAt sql.NullTime
+	err := row.Scan(
+		&report.ID,
+		&report.TargetType,
+		&report.TargetID,
+		&report.TargetUserID,
+		&report.Content,
+		&report.ReporterID,
+		&report.ReporterName,
+		&report.Reason,
+		&report.Note,
+		&report.Status,
+		&report.ReportCount,
+		&report.ClaimedBy,
+		&claimedAt,
+		&report.ResolvedBy,
+		&resolvedAt,
+		&report.Resolution,
+		&report.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if claimedAt.Valid {
+		report.ClaimedAt = &claimedAt.Time
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &report, nil
+}
+
+// ReportTargetOwner returns the user behind a reported comment, review or profile, it fails if there is
+// nothing to report
+func (m *DBModel) ReportTargetOwner(targetType string, targetID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var query string
+	switch targetType {
+	case ReportTargetComment:
+		query = `select user_id from comments where id = $1 and is_deleted = false`
+	case ReportTargetReview:
+		query = `select user_id from reviews where id = $1`
+	case ReportTargetProfile:
+		query = `select id from users where id = $1`
+	default:
+		return 0, errors.New("invalid target type")
+	}
+
+	var userID int
+	err := m.DB.QueryRowContext(ctx, query, targetID).Scan(&userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s not found", targetType)
+	}
+
+	return userID, nil
+}
+
+// setTargetHidden hides or shows a reported comment or review, it returns false if nothing changed. byReports
+// tells the change comes from the reports: a target they hide is marked as hidden by reports, and showing it
+// again only applies to such a target, never to one a moderator or the content filter hid
+func setTargetHidden(ctx context.Context, tx *sql.Tx, targetType string, targetID int, hidden, byReports bool) (bool, error) {
+	table, ok := hideableTargets[targetType]
+	if !ok {
+		return false, nil
+	}
+
+	if hidden && !byReports {
+		// a moderator or the content filter takes over a target the reports already hid
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`update %s set hidden_by_reports = false where id = $1`, table), targetID)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	only := "true"
+	if !hidden && byReports {
+		only = "hidden_by_reports"
+	}
+
+	var movieID int
+	var counted bool
+	stmt := fmt.Sprintf(`update %s set is_hidden = $1, hidden_by_reports = $1 and $3 where id = $2 and is_hidden <> $1 and %s
+	RETURNING movie_id, %s`, table, only, countedColumn[targetType])
+	err := tx.QueryRowContext(ctx, stmt, hidden, targetID, byReports).Scan(&movieID, &counted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// the comments of a shadow muted user are not counted whether they are hidden or not
+	if counted && targetType == ReportTargetComment {
+		muted, err := authorShadowMuted(ctx, tx, targetID)
+		if err != nil {
+			return false, err
+		}
+		counted = !muted
+	}
+
+	// a shown target is counted in the statistics of its movie, a hidden one is not
+	if counted {
+		change := 1
+		if hidden {
+			change = -1
+		}
+
+		delta := statsDelta{reviews: change}
+		if targetType == ReportTargetComment {
+			delta = statsDelta{comments: change}
+		}
+
+		err = updateMovieStats(ctx, tx, movieID, delta)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// insertModerationAction adds an entry to the audit trail
+func insertModerationAction(ctx context.Context, tx *sql.Tx, action *ModerationAction) error {
+	stmt := `insert into moderation_actions (report_id, moderator_id, target_type, target_id, target_user_id, action, note, created_at)
+	values($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := tx.ExecContext(ctx, stmt,
+		nullInt(action.ReportID),
+		nullInt(action.ModeratorID),
+		action.TargetType,
+		action.TargetID,
+		nullInt(action.TargetUserID),
+		action.Action,
+		sql.NullString{String: action.Note, Valid: action.Note != ""},
+		time.Now(),
+	)
+
+	return err
+}
+
+// InsertReport is help to add a report. Once hideThreshold users reported the same comment or review
+// it gets hidden until a moderator resolves the reports, it returns whether the target got hidden
+func (m *DBModel) InsertReport(report *Report, hideThreshold int) (int, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, errors.New("failed to add the report")
+	}
+	defer tx.Rollback()
+
+	var reportID int
+	stmt := `insert into reports (target_type, target_id, target_user_id, reporter_id, reason, note, status, created_at, updated_at)
+	values($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	on conflict (target_type, target_id, reporter_id) do nothing
+	RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		report.TargetType,
+		report.TargetID,
+		nullInt(report.TargetUserID),
+		report.ReporterID,
+		report.Reason,
+		sql.NullString{String: report.Note, Valid: report.Note != ""},
+		ReportStatusOpen,
+		time.Now(),
+		time.Now(),
+	).Scan(&reportID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, ErrDuplicateReport
+	}
+	if err != nil {
+		return 0, false, errors.New("failed to add the report")
+	}
+
+	hidden := false
+	if _, ok := hideableTargets[report.TargetType]; ok {
+		var reporters int
+		query := `select count(distinct reporter_id) from reports where target_type = $1 and target_id = $2 and status <> $3`
+		err = tx.QueryRowContext(ctx, query, report.TargetType, report.TargetID, ReportStatusResolved).Scan(&reporters)
+		if err != nil {
+			return 0, false, errors.New("failed to add the report")
+		}
+
+		if reporters >= hideThreshold {
+			hidden, err = setTargetHidden(ctx, tx, report.TargetType, report.TargetID, true, true)
+			if err != nil {
+				return 0, false, errors.New("failed to add the report")
+			}
+		}
+
+		if hidden {
+			err = insertModerationAction(ctx, tx, &ModerationAction{
+				ReportID:     reportID,
+				TargetType:   report.TargetType,
+				TargetID:     report.TargetID,
+				TargetUserID: report.TargetUserID,
+				Action:       ModerationHide,
+				Note:         fmt.Sprintf("hidden automatically after %d reports", reporters),
+			})
+			if err != nil {
+				return 0, false, errors.New("failed to add the report")
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, false, errors.New("failed to add the report")
+	}
+
+	return reportID, hidden, nil
+}
+
+// QueueForReview is help to hide a comment or a review flagged by the content filter and report it
+// to the moderators, a flagged profile is only reported
+func (m *DBModel) QueueForReview(targetType string, targetID, targetUserID int, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to queue for review")
+	}
+	defer tx.Rollback()
+
+	var reportID int
+	stmt := `insert into reports (target_type, target_id, target_user_id, reason, note, status, created_at, updated_at)
+	values($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		targetType,
+		targetID,
+		nullInt(targetUserID),
+		ReportReasonContentFilter,
+		note,
+		ReportStatusOpen,
+		time.Now(),
+		time.Now(),
+	).Scan(&reportID)
+	if err != nil {
+		return errors.New("failed to queue for review")
+	}
+
+	hidden, err := setTargetHidden(ctx, tx, targetType, targetID, true, false)
+	if err != nil {
+		return errors.New("failed to queue for review")
+	}
+
+	if hidden {
+		err = insertModerationAction(ctx, tx, &ModerationAction{
+			ReportID:     reportID,
+			TargetType:   targetType,
+			TargetID:     targetID,
+			TargetUserID: targetUserID,
+			Action:       ModerationHide,
+			Note:         "hidden by the content filter: " + note,
+		})
+		if err != nil {
+			return errors.New("failed to queue for review")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to queue for review")
+	}
+
+	return nil
+}
+
+// GetReport returns one report and error, if any
+func (m *DBModel) GetReport(id int) (*Report, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanReport(m.DB.QueryRowContext(ctx, reportQuery+` WHERE rp.id = $1`, id))
+}
+
+// GetReports returns a page of the moderation queue, oldest reports first. Without a status filter
+// only the reports that are not resolved yet are returned
+func (m *DBModel) GetReports(page, perPage int, filter *ReportFilter) (*PaginatedReports, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	// add where query according to filter condition
+	var dbArgs []interface{}
+	where := ""
+
+	if filter.Status != "" {
+		dbArgs = append(dbArgs, filter.Status)
+		where += fmt.Sprintf(" WHERE rp.status = $%d", len(dbArgs))
+	} else {
+		dbArgs = append(dbArgs, ReportStatusResolved)
+		where += fmt.Sprintf(" WHERE rp.status <> $%d", len(dbArgs))
+	}
+
+	if filter.TargetType != "" {
+		dbArgs = append(dbArgs, filter.TargetType)
+		where += fmt.Sprintf(" AND rp.target_type = $%d", len(dbArgs))
+	}
+
+	var totalCount int
+	countQuery := `SELECT COUNT(rp.id) FROM reports rp` + where
+	err := m.DB.QueryRowContext(ctx, countQuery, dbArgs...).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// pagination query
+	paginationQuery := fmt.Sprintf(" ORDER BY rp.created_at ASC, rp.id ASC LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, reportQuery+where+paginationQuery, dbArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*Report{}
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedReports{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Reports:     reports,
+	}, nil
+}
+
+// lockReport reads the target and the status of a report and locks it until the transaction ends
+func lockReport(ctx context.Context, tx *sql.Tx, id, moderatorID int) (*Report, error) {
+	var report Report
+	var targetUserID, claimedBy sql.NullInt64
+
+	query := `select id, target_type, target_id, target_user_id, status, claimed_by from reports where id = $1 for update`
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&report.ID,
+		&report.TargetType,
+		&report.TargetID,
+		&targetUserID,
+		&report.Status,
+		&claimedBy,
+	)
+	if err != nil {
+		return nil, errors.New("report not found")
+	}
+
+	report.TargetUserID = int(targetUserID.Int64)
+	report.ClaimedBy = int(claimedBy.Int64)
+
+	if report.Status == ReportStatusResolved {
+		return nil, ErrReportResolved
+	}
+
+	if report.Status == ReportStatusClaimed && report.ClaimedBy != moderatorID {
+		return nil, ErrReportClaimed
+	}
+
+	return &report, nil
+}
+
+// ClaimReport is help a moderator to take a report, the other open reports on the same target are
+// claimed with it so two moderators don't work on the same thing
+func (m *DBModel) ClaimReport(id, moderatorID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to claim the report")
+	}
+	defer tx.Rollback()
+
+	report, err := lockReport(ctx, tx, id, moderatorID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `update reports set status = $1, claimed_by = $2, claimed_at = $3, updated_at = $3
+	where (id = $4) or (target_type = $5 and target_id = $6 and status = $7)`
+
+	_, err = tx.ExecContext(ctx, stmt,
+		ReportStatusClaimed,
+		moderatorID,
+		time.Now(),
+		report.ID,
+		report.TargetType,
+		report.TargetID,
+		ReportStatusOpen,
+	)
+	if err != nil {
+		return errors.New("failed to claim the report")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to claim the report")
+	}
+
+	return nil
+}
+
+// ResolveReport is help a moderator to act on a report. The action is applied to the reported target,
+// every report on that target is resolved with it and the decision is kept in the audit trail
+func (m *DBModel) ResolveReport(id, moderatorID int, action, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to resolve the report")
+	}
+	defer tx.Rollback()
+
+	report, err := lockReport(ctx, tx, id, moderatorID)
+	if err != nil {
+		return err
+	}
+
+	if !ModerationActionAllowed(report.TargetType, action) {
+		return ErrInvalidModerationAction
+	}
+
+	switch action {
+	case ModerationDismiss:
+		// content hidden because of the reports is shown again, not the content a moderator or the filter hid
+		_, err = setTargetHidden(ctx, tx, report.TargetType, report.TargetID, false, true)
+	case ModerationHide:
+		_, err = setTargetHidden(ctx, tx, report.TargetType, report.TargetID, true, false)
+	case ModerationDelete:
+		if report.TargetType == ReportTargetComment {
+			err = deleteComment(ctx, tx, report.TargetID, moderatorID, note)
+		} else {
+			err = deleteReview(ctx, tx, report.TargetID)
+		}
+	case ModerationBan:
+		if report.TargetUserID > 0 {
+			// admins and moderators are only sanctioned by the admins
+			var staff bool
+			query := `select user_type in ('admin', 'moderator') from users where id = $1`
+			err = tx.QueryRowContext(ctx, query, report.TargetUserID).Scan(&staff)
+			if errors.Is(err, sql.ErrNoRows) {
+				err = nil
+				break
+			}
+			if err != nil {
+				break
+			}
+			if staff {
+				return ErrStaffBan
+			}
+
+			stmt := `insert into user_sanctions (user_id, kind, reason, issued_by, created_at) values($1, $2, $3, $4, $5)`
+			_, err = tx.ExecContext(ctx, stmt, report.TargetUserID, SanctionBan, note, moderatorID, time.Now())
+		}
+	case ModerationWarn:
+		// a warning is only recorded in the audit trail
+	}
+	if err != nil {
+		return errors.New("failed to resolve the report")
+	}
+
+	stmt := `update reports set status = $1, resolved_by = $2, resolved_at = $3, resolution = $4, updated_at = $3
+	where target_type = $5 and target_id = $6 and status <> $1`
+
+	_, err = tx.ExecContext(ctx, stmt,
+		ReportStatusResolved,
+		moderatorID,
+		time.Now(),
+		action,
+		report.TargetType,
+		report.TargetID,
+	)
+	if err != nil {
+		return errors.New("failed to resolve the report")
+	}
+
+	err = insertModerationAction(ctx, tx, &ModerationAction{
+		ReportID:     report.ID,
+		ModeratorID:  moderatorID,
+		TargetType:   report.TargetType,
+		TargetID:     report.TargetID,
+		TargetUserID: report.TargetUserID,
+		Action:       action,
+		Note:         note,
+	})
+	if err != nil {
+		return errors.New("failed to resolve the report")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to resolve the report")
+	}
+
+	return nil
+}
+
+// GetModerationActions returns the audit trail of a reported target, newest first
+func (m *DBModel) GetModerationActions(targetType string, targetID int) ([]*ModerationAction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select
+		a.id, coalesce(a.report_id, 0), coalesce(a.moderator_id, 0), coalesce(u.name, ''),
+		a.target_type, a.target_id, coalesce(a.target_user_id, 0), a.action, coalesce(a.note, ''), a.created_at
+	from moderation_actions a
+	left join users u on (u.id = a.moderator_id)
+	where a.target_type = $1 and a.target_id = $2
+	order by a.created_at desc, a.id desc`
+
+	rows, err := m.DB.QueryContext(ctx, query, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []*ModerationAction{}
+	for rows.Next() {
+		var action ModerationAction
+		err := rows.Scan(
+			&action.ID,
+			&action.ReportID,
+			&action.ModeratorID,
+			&action.ModeratorName,
+			&action.TargetType,
+			&action.TargetID,
+			&action.TargetUserID,
+			&action.Action,
+			&action.Note,
+			&action.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, &action)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,302 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/raihan2bd/filmwise/spoiler"
+)
+
+// sort options for reviews listing
+const (
+	ReviewSortNewest     = "newest"
+	ReviewSortOldest     = "oldest"
+	ReviewSortRatingHigh = "rating_high"
+	ReviewSortRatingLow  = "rating_low"
+)
+
+// ErrReviewWithoutRating is returned when a user reviews a movie they have not rated
+var ErrReviewWithoutRating = errors.New("rate the movie before writing a review")
+
+// reviewQuery selects a review with its author and the rating the author gave to the movie
+const reviewQuery = `SELECT
+	rv.id, rv.movie_id, m.title, rv.user_id, u.name, COALESCE(r.rating, 0),
+	rv.title, rv.body, rv.body_segments, rv.is_spoiler, rv.is_hidden, rv.created_at, rv.updated_at
+FROM
+	reviews rv
+	LEFT JOIN movies m ON (m.id = rv.movie_id)
+	LEFT JOIN users u ON (u.id = rv.user_id)
+	LEFT JOIN ratings r ON (r.movie_id = rv.movie_id AND r.user_id = rv.user_id)`
+
+// scanReview reads a row selected by reviewQuery
+func scanReview(row interface{ Scan(...interface{}) error }) (*Review, error) {
+	var review Review
+	var segments []byte
+	err := row.Scan(
+		&review.ID,
+		&review.MovieID,
+		&review.MovieTitle,
+		&review.UserID,
+		&review.UserName,
+		&review.Rating,
+		&review.Title,
+		&review.Body,
+		&segments,
+		&review.IsSpoiler,
+		&review.IsHidden,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	review.BodySegments = decodeSegments(segments, review.Body)
+
+	return &review, nil
+}
+
+// RenderSpoilers sets the body of a review from its segments, spoilers are redacted unless revealed.
+// A review flagged as a spoiler is hidden as a whole.
+func (rv *Review) RenderSpoilers(reveal bool) {
+	segments := rv.BodySegments
+	if segments == nil {
+		segments = spoiler.Parse(rv.Body)
+	}
+
+	if rv.IsSpoiler {
+		segments = spoiler.Whole(spoiler.Text(segments))
+	}
+
+	if !reveal {
+		segments = spoiler.Redact(segments)
+	}
+
+	rv.BodySegments = segments
+	rv.Body = spoiler.Text(segments)
+}
+
+// GetReview returns one review and error, if any
+func (m *DBModel) GetReview(id int) (*Review, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanReview(m.DB.QueryRowContext(ctx, reviewQuery+` WHERE rv.id = $1`, id))
+}
+
+// FindReview returns the id of the review a user wrote for a movie
+func (m *DBModel) FindReview(movieID, userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `select id from reviews where movie_id = $1 and user_id = $2`
+
+	reviewID := 0
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(&reviewID)
+	if err != nil {
+		return 0, errors.New("Review not found")
+	}
+
+	return reviewID, nil
+}
+
+// InsertReview is help to add a review, the user must have rated the movie first
+func (m *DBModel) InsertReview(review *Review) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var ratingID int
+	query := `select id from ratings where movie_id = $1 and user_id = $2`
+	err := m.DB.QueryRowContext(ctx, query, review.MovieID, review.UserID).Scan(&ratingID)
+	if err != nil {
+		return 0, ErrReviewWithoutRating
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to add the review")
+	}
+	defer tx.Rollback()
+
+	var reviewID int
+	stmt := `insert into reviews (movie_id, user_id, title, body, body_segments, is_spoiler, created_at, updated_at)
+						values($1, $2, $3, $4, $5, $6, $7, $8)
+						RETURNING id`
+
+	err = tx.QueryRowContext(ctx, stmt,
+		review.MovieID,
+		review.UserID,
+		review.Title,
+		review.Body,
+		encodeSegments(review.Body),
+		review.IsSpoiler,
+		time.Now(),
+		time.Now(),
+	).Scan(&reviewID)
+	if err != nil {
+		return reviewID, errors.New("failed to add the review")
+	}
+
+	err = updateMovieStats(ctx, tx, review.MovieID, statsDelta{reviews: 1})
+	if err != nil {
+		return 0, errors.New("failed to add the review")
+	}
+
+	err = recordActivity(ctx, tx, review.UserID, ActivityReviewed, review.MovieID, reviewID)
+	if err != nil {
+		return 0, errors.New("failed to add the review")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to add the review")
+	}
+
+	return reviewID, nil
+}
+
+// UpdateReview is help to edit a review
+func (m *DBModel) UpdateReview(review *Review) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var reviewID int
+	stmt := `update reviews set title = $1, body = $2, body_segments = $3, is_spoiler = $4, updated_at = $5 where id = $6
+	RETURNING id`
+
+	err := m.DB.QueryRowContext(ctx, stmt,
+		review.Title,
+		review.Body,
+		encodeSegments(review.Body),
+		review.IsSpoiler,
+		time.Now(),
+		review.ID,
+	).Scan(&reviewID)
+	if err != nil {
+		return reviewID, errors.New("failed to update the review")
+	}
+
+	return reviewID, nil
+}
+
+// DeleteReview is help to delete a review
+func (m *DBModel) DeleteReview(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to delete the review")
+	}
+	defer tx.Rollback()
+
+	err = deleteReview(ctx, tx, id)
+	if err != nil {
+		return errors.New("failed to delete the review")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to delete the review")
+	}
+
+	return nil
+}
+
+// deleteReview removes a review and takes it out of the statistics of its movie unless it was hidden already
+func deleteReview(ctx context.Context, tx *sql.Tx, id int) error {
+	var movieID int
+	var hidden bool
+	stmt := "delete from reviews where id = $1 RETURNING movie_id, is_hidden"
+
+	err := tx.QueryRowContext(ctx, stmt, id).Scan(&movieID, &hidden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if hidden {
+		return nil
+	}
+
+	return updateMovieStats(ctx, tx, movieID, statsDelta{reviews: -1})
+}
+
+// GetReviews returns a page of reviews of a movie or written by a user
+func (m *DBModel) GetReviews(page, perPage int, filter *ReviewFilter) (*PaginatedReviews, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	// add where query according to filter condition, reviews hidden by moderators are left out
+	var dbArgs []interface{}
+	where := " WHERE rv.is_hidden = false"
+
+	if filter.MovieID > 0 {
+		dbArgs = append(dbArgs, filter.MovieID)
+		where += fmt.Sprintf(" AND rv.movie_id = $%d", len(dbArgs))
+	}
+
+	if filter.UserID > 0 {
+		dbArgs = append(dbArgs, filter.UserID)
+		where += fmt.Sprintf(" AND rv.user_id = $%d", len(dbArgs))
+	}
+
+	dbArgs = append(dbArgs, filter.ViewerID)
+	where += " AND " + visibleAuthor("rv.user_id", len(dbArgs))
+
+	//	add order by query
+	orderByQuery := ""
+	switch filter.SortBy {
+	case ReviewSortOldest:
+		orderByQuery = " ORDER BY rv.created_at ASC, rv.id ASC"
+	case ReviewSortRatingHigh:
+		orderByQuery = " ORDER BY r.rating DESC NULLS LAST, rv.created_at DESC"
+	case ReviewSortRatingLow:
+		orderByQuery = " ORDER BY r.rating ASC NULLS LAST, rv.created_at DESC"
+	default:
+		orderByQuery = " ORDER BY rv.created_at DESC, rv.id DESC"
+	}
+
+	var totalCount int
+	countQuery := `SELECT COUNT(rv.id) FROM reviews rv` + where
+	err := m.DB.QueryRowContext(ctx, countQuery, dbArgs...).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// pagination query
+	paginationQuery := fmt.Sprintf(" LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, reviewQuery+where+orderByQuery+paginationQuery, dbArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []*Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedReviews{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Reviews:     reviews,
+	}, nil
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// kinds of sanction an admin can put on a user
+const (
+	SanctionSuspension = "suspension"
+	SanctionBan        = "ban"
+	SanctionShadowMute = "shadow_mute"
+)
+
+// activeSanction is the condition for a sanction aliased as s to be in effect
+const activeSanction = `s.revoked_at IS NULL AND (s.ends_at IS NULL OR s.ends_at > $2)`
+
+// sanctionQuery selects a sanction with the name of the admin who issued it
+const sanctionQuery = `SELECT
+	s.id, s.user_id, s.kind, s.reason, COALESCE(s.issued_by, 0), COALESCE(u.name, ''),
+	s.ends_at, s.revoked_at, COALESCE(s.revoked_by, 0), COALESCE(s.revoke_reason, ''), s.created_at
+FROM
+	user_sanctions s
+	LEFT JOIN users u ON (u.id = s.issued_by)`
+
+// scanSanction reads a row selected by sanctionQuery
+func scanSanction(row interface{ Scan(...interface{}) error }) (*Sanction, error) {
+	var sanction Sanction
+	var endsAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&sanction.ID,
+		&sanction.UserID,
+		&sanction.Kind,
+		&sanction.Reason,
+		&sanction.IssuedBy,
+		&sanction.IssuerName,
+		&endsAt,
+		&revokedAt,
+		&sanction.RevokedBy,
+		&sanction.RevokeReason,
+		&sanction.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if endsAt.Valid {
+		sanction.EndsAt = &endsAt.Time
+	}
+	if revokedAt.Valid {
+		sanction.RevokedAt = &revokedAt.Time
+	}
+	sanction.Active = sanction.RevokedAt == nil && (sanction.EndsAt == nil || sanction.EndsAt.After(time.Now()))
+
+	return &sanction, nil
+}
+
+// InsertSanction is help to add a sanction to a user, a shadow mute takes the comments of the user out of
+// the comment count of the movies
+func (m *DBModel) InsertSanction(sanction *Sanction) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to add the sanction")
+	}
+	defer tx.Rollback()
+
+	var sanctionID int
+	stmt := `insert into user_sanctions (user_id, kind, reason, issued_by, ends_at, created_at)
+	values($1, $2, $3, $4, $5, $6)
+	RETURNING id`
+
+	var endsAt sql.NullTime
+	if sanction.EndsAt != nil {
+		endsAt = sql.NullTime{Time: *sanction.EndsAt, Valid: true}
+	}
+
+	err = tx.QueryRowContext(ctx, stmt,
+		sanction.UserID,
+		sanction.Kind,
+		sanction.Reason,
+		nullInt(sanction.IssuedBy),
+		endsAt,
+		time.Now(),
+	).Scan(&sanctionID)
+	if err != nil {
+		return 0, errors.New("failed to add the sanction")
+	}
+
+	if sanction.Kind == SanctionShadowMute {
+		err = recountUserComments(ctx, tx, sanction.UserID)
+		if err != nil {
+			return 0, errors.New("failed to add the sanction")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to add the sanction")
+	}
+
+	return sanctionID, nil
+}
+
+// RecountEndedShadowMutes counts the comments of the users whose shadow mute ended on its own again, a revoked
+// mute is recounted when it is lifted. It returns the number of mutes that caught up
+func (m *DBModel) RecountEndedShadowMutes() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// the update locks the mutes so two instances never recount the same one
+	stmt := `update user_sanctions s set recounted_at = $1
+	where s.kind = $2 and s.revoked_at is null and s.recounted_at is null and s.ends_at <= $1
+	RETURNING s.user_id`
+
+	rows, err := tx.QueryContext(ctx, stmt, time.Now(), SanctionShadowMute)
+	if err != nil {
+		return 0, err
+	}
+
+	var userIDs []int
+	seen := map[int]bool{}
+	ended := 0
+	for rows.Next() {
+		var userID int
+		if err = rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		ended++
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, userID := range userIDs {
+		err = recountUserComments(ctx, tx, userID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return ended, nil
+}
+
+// GetSanction returns one sanction and error, if any
+func (m *DBModel) GetSanction(id int) (*Sanction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return scanSanction(m.DB.QueryRowContext(ctx, sanctionQuery+` WHERE s.id = $1`, id))
+}
+
+// GetUserSanctions returns every sanction of a user, newest first
+func (m *DBModel) GetUserSanctions(userID int) ([]*Sanction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, sanctionQuery+` WHERE s.user_id = $1 ORDER BY s.created_at DESC, s.id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sanctions := []*Sanction{}
+	for rows.Next() {
+		sanction, err := scanSanction(rows)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, sanction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sanctions, nil
+}
+
+// GetAccessSanction returns the ban or the suspension that keeps a user out right now, a ban comes
+// before the suspension that ends last. It returns nil when the user is not blocked
+func (m *DBModel) GetAccessSanction(userID int) (*Sanction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := sanctionQuery + ` WHERE s.user_id = $1 AND s.kind IN ('ban', 'suspension') AND ` + activeSanction + `
+	ORDER BY s.kind = 'ban' DESC, s.ends_at DESC NULLS FIRST
+	LIMIT 1`
+
+	sanction, err := scanSanction(m.DB.QueryRowContext(ctx, query, userID, time.Now()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sanction, nil
+}
+
+// RevokeSanction is help to lift a sanction before it ends, lifting a shadow mute counts the comments of the
+// user again
+func (m *DBModel) RevokeSanction(id, revokedBy int, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to revoke the sanction")
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var kind string
+	stmt := `update user_sanctions s set revoked_at = $2, revoked_by = $3, revoke_reason = $4
+	where s.id = $1 and ` + activeSanction + `
+	RETURNING s.user_id, s.kind`
+
+	err = tx.QueryRowContext(ctx, stmt, id, time.Now(), nullInt(revokedBy), reason).Scan(&userID, &kind)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("sanction is not active")
+	}
+	if err != nil {
+		return errors.New("failed to revoke the sanction")
+	}
+
+	if kind == SanctionShadowMute {
+		err = recountUserComments(ctx, tx, userID)
+		if err != nil {
+			return errors.New("failed to revoke the sanction")
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to revoke the sanction")
+	}
+
+	return nil
+}
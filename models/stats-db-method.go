@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// statsDelta is a change to the statistics of a movie
+type statsDelta struct {
+	ratingSum float64
+	ratings   int
+	comments  int
+	favorites int
+	reviews   int
+}
+
+// updateMovieStats applies a change to the movie_stats row of a movie inside the transaction of the write
+// that caused it, the row is created on the first change
+func updateMovieStats(ctx context.Context, tx *sql.Tx, movieID int, d statsDelta) error {
+	stmt := `insert into movie_stats as ms (movie_id, rating_sum, rating_count, comment_count, favorite_count, review_count, updated_at)
+	values($1, $2, $3, $4, $5, $6, $7)
+	on conflict (movie_id) do update set
+		rating_sum = ms.rating_sum + excluded.rating_sum,
+		rating_count = ms.rating_count + excluded.rating_count,
+		comment_count = ms.comment_count + excluded.comment_count,
+		favorite_count = ms.favorite_count + excluded.favorite_count,
+		review_count = ms.review_count + excluded.review_count,
+		updated_at = excluded.updated_at`
+
+	_, err := tx.ExecContext(ctx, stmt, movieID, d.ratingSum, d.ratings, d.comments, d.favorites, d.reviews, time.Now())
+	return err
+}
+
+// recountUserComments recounts the comments of the movies a user commented on, after the user got shadow
+// muted or the mute was lifted
+func recountUserComments(ctx context.Context, tx *sql.Tx, userID int) error {
+	stmt := `update movie_stats ms set
+		comment_count = (select count(c.id) from comments c where c.movie_id = ms.movie_id and c.is_deleted = false
+			and c.is_hidden = false and not ` + shadowMuted("c.user_id", "$2") + `),
+		updated_at = $2
+	where ms.movie_id in (select movie_id from comments where user_id = $1)`
+
+	_, err := tx.ExecContext(ctx, stmt, userID, time.Now())
+	return err
+}
+
+// ReconcileMovieStats rebuilds movie_stats from the ratings, comments, favorites and reviews tables, it also
+// catches up with the shadow mutes that ended. It returns the number of movies whose statistics were missing
+// or wrong
+func (m *DBModel) ReconcileMovieStats() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	stmt := `insert into movie_stats as ms (movie_id, rating_sum, rating_count, comment_count, favorite_count, review_count, updated_at)
+	select
+		m.id,
+		coalesce((select sum(r.rating::float8) from ratings r where r.movie_id = m.id), 0),
+		(select count(r.id) from ratings r where r.movie_id = m.id),
+		(select count(c.id) from comments c where c.movie_id = m.id and c.is_deleted = false and c.is_hidden = false
+			and not ` + shadowMuted("c.user_id", "$1") + `),
+		(select count(f.id) from favorites f where f.movie_id = m.id),
+		(select count(rv.id) from reviews rv where rv.movie_id = m.id and rv.is_hidden = false),
+		$1
+	from movies m
+	on conflict (movie_id) do update set
+		rating_sum = excluded.rating_sum,
+		rating_count = excluded.rating_count,
+		comment_count = excluded.comment_count,
+		favorite_count = excluded.favorite_count,
+		review_count = excluded.review_count,
+		updated_at = excluded.updated_at
+	where (ms.rating_sum, ms.rating_count, ms.comment_count, ms.favorite_count, ms.review_count) is distinct from
+		(excluded.rating_sum, excluded.rating_count, excluded.comment_count, excluded.favorite_count, excluded.review_count)`
+
+	result, err := m.DB.ExecContext(ctx, stmt, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
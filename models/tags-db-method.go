@@ -0,0 +1,470 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MovieMaxTags is the number of tags a movie can have
+const MovieMaxTags = 30
+
+// status of a tag suggestion
+const (
+	TagSuggestionPending  = "pending"
+	TagSuggestionApproved = "approved"
+	TagSuggestionRejected = "rejected"
+)
+
+var (
+	// ErrTagNotFound is returned when the tag does not exist
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrTagNotOnMovie is returned when the movie does not have the tag
+	ErrTagNotOnMovie = errors.New("the movie does not have this tag")
+	// ErrTagOnMovie is returned when a tag the movie already has is suggested
+	ErrTagOnMovie = errors.New("the movie already has this tag")
+	// ErrTooManyTags is returned when a movie has MovieMaxTags tags
+	ErrTooManyTags = fmt.Errorf("a movie can have up to %d tags", MovieMaxTags)
+	// ErrTagSuggestionExists is returned when the tag is already waiting for a moderator on the movie
+	ErrTagSuggestionExists = errors.New("this tag is already suggested for the movie")
+	// ErrTagSuggestionNotFound is returned when the suggestion does not exist or is already reviewed
+	ErrTagSuggestionNotFound = errors.New("tag suggestion not found")
+)
+
+// TagName trims a tag name and collapses its inner spaces
+func TagName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// findOrCreateTag returns the id of the tag with the name, the tag is created when it does not exist yet.
+// Names that only differ by case or punctuation are the same tag
+func findOrCreateTag(ctx context.Context, tx *sql.Tx, name string, userID int) (int, error) {
+	slug := slugify(name)
+	if slug == "" {
+		return 0, errors.New("the tag should have letters or digits")
+	}
+
+	var id int
+	stmt := `insert into tags (name, slug, created_by, created_at) values($1, $2, $3, $4)
+	on conflict (slug) do update set slug = excluded.slug
+	RETURNING id`
+
+	err := tx.QueryRowContext(ctx, stmt, TagName(name), slug, userID, time.Now()).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// tagMovie puts a tag on a movie in tx, tagging it again keeps the first one
+func tagMovie(ctx context.Context, tx *sql.Tx, movieID int, name string, userID int) (int, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, `select count(tag_id) from movie_tags where movie_id = $1`, movieID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	tagID, err := findOrCreateTag(ctx, tx, name, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `insert into movie_tags (movie_id, tag_id, added_by, created_at) values($1, $2, $3, $4)
+	on conflict (movie_id, tag_id) do nothing`
+
+	result, err := tx.ExecContext(ctx, stmt, movieID, tagID, userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if n, _ := result.RowsAffected(); n > 0 && count >= MovieMaxTags {
+		return 0, ErrTooManyTags
+	}
+
+	return tagID, nil
+}
+
+// AddMovieTag is help to put a tag on a movie, the tag is created when it does not exist yet
+func (m *DBModel) AddMovieTag(movieID int, name string, userID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New("failed to tag the movie")
+	}
+	defer tx.Rollback()
+
+	// lock the movie so its tags are counted one writer at a time
+	var id int
+	err = tx.QueryRowContext(ctx, `select id from movies where id = $1 for update`, movieID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("invalid movie id")
+	}
+	if err != nil {
+		return 0, errors.New("failed to tag the movie")
+	}
+
+	tagID, err := tagMovie(ctx, tx, movieID, name, userID)
+	if errors.Is(err, ErrTooManyTags) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, errors.New("failed to tag the movie")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.New("failed to tag the movie")
+	}
+
+	return tagID, nil
+}
+
+// RemoveMovieTag is help to take a tag off a movie
+func (m *DBModel) RemoveMovieTag(movieID, tagID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from movie_tags where movie_id = $1 and tag_id = $2`, movieID, tagID)
+	if err != nil {
+		return errors.New("failed to remove the tag")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTagNotOnMovie
+	}
+
+	return nil
+}
+
+// DeleteTag is help to delete a tag from every movie
+func (m *DBModel) DeleteTag(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from tags where id = $1`, id)
+	if err != nil {
+		return errors.New("failed to delete the tag")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// SuggestTag is help to suggest a tag for a movie, it waits for a moderator before it shows on the movie
+func (m *DBModel) SuggestTag(movieID, userID int, name string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	name = TagName(name)
+	slug := slugify(name)
+	if slug == "" {
+		return 0, errors.New("the tag should have letters or digits")
+	}
+
+	var exists, tagged bool
+	query := `select
+		exists (select 1 from movies where id = $1),
+		exists (select 1 from movie_tags mt join tags t on (t.id = mt.tag_id) where mt.movie_id = $1 and t.slug = $2)`
+	err := m.DB.QueryRowContext(ctx, query, movieID, slug).Scan(&exists, &tagged)
+	if err != nil {
+		return 0, errors.New("failed to suggest the tag")
+	}
+	if !exists {
+		return 0, errors.New("invalid movie id")
+	}
+	if tagged {
+		return 0, ErrTagOnMovie
+	}
+
+	var id int
+	stmt := `insert into tag_suggestions (movie_id, user_id, tag_name, status, created_at) values($1, $2, $3, $4, $5)
+	on conflict (movie_id, lower(tag_name)) where status = '` + TagSuggestionPending + `' do nothing
+	RETURNING id`
+
+	err = m.DB.QueryRowContext(ctx, stmt, movieID, userID, name, TagSuggestionPending, time.Now()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrTagSuggestionExists
+	}
+	if err != nil {
+		return 0, errors.New("failed to suggest the tag")
+	}
+
+	return id, nil
+}
+
+// GetTagSuggestions returns a page of the tag suggestions with the status, the oldest first
+func (m *DBModel) GetTagSuggestions(status string, page, perPage int) (*PaginatedTagSuggestions, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	var totalCount int
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(id) FROM tag_suggestions WHERE status = $1`, status).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ts.id, ts.movie_id, m.title, ts.user_id, COALESCE(u.name, ''), ts.tag_name, ts.status,
+		COALESCE(ts.reviewed_by, 0), ts.reviewed_at, ts.created_at
+	FROM
+		tag_suggestions ts
+		JOIN movies m ON (m.id = ts.movie_id)
+		LEFT JOIN users u ON (u.id = ts.user_id)
+	WHERE ts.status = $1
+	ORDER BY ts.created_at ASC, ts.id ASC
+	LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.QueryContext(ctx, query, status, perPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*TagSuggestion{}
+	for rows.Next() {
+		var s TagSuggestion
+		var reviewedAt sql.NullTime
+		err = rows.Scan(
+			&s.ID,
+			&s.MovieID,
+			&s.MovieTitle,
+			&s.UserID,
+			&s.UserName,
+			&s.TagName,
+			&s.Status,
+			&s.ReviewedBy,
+			&reviewedAt,
+			&s.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if reviewedAt.Valid {
+			s.ReviewedAt = &reviewedAt.Time
+		}
+
+		suggestions = append(suggestions, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedTagSuggestions{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Suggestions: suggestions,
+	}, nil
+}
+
+// ReviewTagSuggestion is help to approve or reject a pending tag suggestion, an approved tag is put on the
+// movie on behalf of the user who suggested it
+func (m *DBModel) ReviewTagSuggestion(id, moderatorID int, approve bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New("failed to review the tag suggestion")
+	}
+	defer tx.Rollback()
+
+	var movieID, userID int
+	var name string
+	query := `select ts.movie_id, ts.user_id, ts.tag_name
+	from tag_suggestions ts
+	where ts.id = $1 and ts.status = $2
+	for update`
+	err = tx.QueryRowContext(ctx, query, id, TagSuggestionPending).Scan(&movieID, &userID, &name)
+	if err == sql.ErrNoRows {
+		return ErrTagSuggestionNotFound
+	}
+	if err != nil {
+		return errors.New("failed to review the tag suggestion")
+	}
+
+	status := TagSuggestionRejected
+	if approve {
+		status = TagSuggestionApproved
+
+		// lock the movie so its tags are counted one writer at a time
+		_, err = tx.ExecContext(ctx, `select id from movies where id = $1 for update`, movieID)
+		if err != nil {
+			return errors.New("failed to review the tag suggestion")
+		}
+
+		_, err = tagMovie(ctx, tx, movieID, name, userID)
+		if errors.Is(err, ErrTooManyTags) {
+			return err
+		}
+		if err != nil {
+			return errors.New("failed to review the tag suggestion")
+		}
+	}
+
+	stmt := `update tag_suggestions set status = $1, reviewed_by = $2, reviewed_at = $3 where id = $4`
+	_, err = tx.ExecContext(ctx, stmt, status, moderatorID, time.Now(), id)
+	if err != nil {
+		return errors.New("failed to review the tag suggestion")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("failed to review the tag suggestion")
+	}
+
+	return nil
+}
+
+// scanTags reads rows of tag id, name, slug and number of movies
+func scanTags(rows *sql.Rows) ([]*Tag, error) {
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var t Tag
+		err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.MovieCount)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// movieTags returns the tags of a movie by name
+func (m *DBModel) movieTags(ctx context.Context, movieID int) ([]*Tag, error) {
+	query := `SELECT t.id, t.name, t.slug, 0
+	FROM
+		movie_tags mt
+		JOIN tags t ON (t.id = mt.tag_id)
+	WHERE mt.movie_id = $1
+	ORDER BY t.name`
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTags(rows)
+}
+
+// GetPopularTags returns up to limit tags with the most movies
+func (m *DBModel) GetPopularTags(limit int) ([]*Tag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT t.id, t.name, t.slug, COUNT(mt.movie_id) AS movie_count
+	FROM
+		tags t
+		JOIN movie_tags mt ON (mt.tag_id = t.id)
+	GROUP BY t.id, t.name, t.slug
+	ORDER BY movie_count DESC, t.name ASC
+	LIMIT $1`
+
+	rows, err := m.DB.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanTags(rows)
+}
+
+// coOccurringTags returns up to limit tags found on the same movies as the tags selected by base, which
+// selects a tag_id column from the $1 parameter. A tag scores the cosine of its co-occurrence with each
+// base tag, summed over them, so tags that go with most of the base tags come first
+func (m *DBModel) coOccurringTags(ctx context.Context, base string, arg interface{}, limit int) ([]*Tag, error) {
+	query := `WITH base AS (` + base + `),
+	counts AS (SELECT tag_id, COUNT(movie_id)::float8 AS movies FROM movie_tags GROUP BY tag_id),
+	pairs AS (
+		SELECT mt.tag_id AS base_id, o.tag_id, COUNT(o.movie_id) AS shared
+		FROM
+			movie_tags mt
+			JOIN movie_tags o ON (o.movie_id = mt.movie_id)
+		WHERE mt.tag_id IN (SELECT tag_id FROM base) AND o.tag_id NOT IN (SELECT tag_id FROM base)
+		GROUP BY mt.tag_id, o.tag_id
+	)
+	SELECT t.id, t.name, t.slug, oc.movies::int, SUM(p.shared / SQRT(bc.movies * oc.movies)) AS score
+	FROM
+		pairs p
+		JOIN counts bc ON (bc.tag_id = p.base_id)
+		JOIN counts oc ON (oc.tag_id = p.tag_id)
+		JOIN tags t ON (t.id = p.tag_id)
+	GROUP BY t.id, t.name, t.slug, oc.movies
+	ORDER BY score DESC, t.id ASC
+	LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, arg, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var t Tag
+		err = rows.Scan(&t.ID, &t.Name, &t.Slug, &t.MovieCount, &t.Score)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetTagBySlug returns a tag with its number of movies and up to related of the tags most often found with it,
+// none when related is zero
+func (m *DBModel) GetTagBySlug(slug string, related int) (*Tag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var t Tag
+	query := `SELECT t.id, t.name, t.slug, t.created_at, (SELECT COUNT(movie_id) FROM movie_tags WHERE tag_id = t.id)
+	FROM tags t
+	WHERE t.slug = $1`
+	err := m.DB.QueryRowContext(ctx, query, slug).Scan(&t.ID, &t.Name, &t.Slug, &t.CreatedAt, &t.MovieCount)
+	if err == sql.ErrNoRows {
+		return nil, ErrTagNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if related > 0 {
+		t.Related, err = m.coOccurringTags(ctx, `SELECT $1::int AS tag_id`, t.ID, related)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+// GetSuggestedTags returns up to limit tags the movie does not have that are often found with its tags
+func (m *DBModel) GetSuggestedTags(movieID, limit int) ([]*Tag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.coOccurringTags(ctx, `SELECT tag_id FROM movie_tags WHERE movie_id = $1`, movieID, limit)
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MovieOrderPopular orders the movies by how many ratings, favorites and comments they got of all time
+const MovieOrderPopular = "popular"
+
+// FeaturedMoviesCount is the number of featured movies, the pinned ones first and then the trending ones
+const FeaturedMoviesCount = 5
+
+// DefaultTrendingWindow is the window of the trending movies when none is given
+const DefaultTrendingWindow = "7d"
+
+// TrendingWindows are the windows the trending movies can be computed over
+var TrendingWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// ErrNotFeatured is returned when the movie is not pinned on the featured movies
+var ErrNotFeatured = errors.New("the movie is not featured")
+
+// how much each kind of activity counts for the trending and popular movies
+const (
+	trendingRatingWeight   = 3
+	trendingFavoriteWeight = 2
+	trendingCommentWeight  = 1
+)
+
+// popularityColumn scores the movie aliased m of all time from ratingStatsQuery
+var popularityColumn = fmt.Sprintf(`(COALESCE(ms.rating_count, 0) * %d + COALESCE(ms.favorite_count, 0) * %d +
+	COALESCE(ms.comment_count, 0) * %d)`, trendingRatingWeight, trendingFavoriteWeight, trendingCommentWeight)
+
+// trendingQuery scores every movie with activity since the since parameter. Each rating, favorite and comment
+// counts for its weight decayed by its age at the now parameter, the decay parameter is the decay time in
+// seconds. It selects movie_id and score
+func trendingQuery(since, now, decay int) string {
+	return fmt.Sprintf(`SELECT e.movie_id, SUM(e.weight * EXP(-EXTRACT(EPOCH FROM ($%[2]d::timestamp - e.at)) / $%[3]d::float8)) AS score
+	FROM (
+		SELECT movie_id, updated_at AS at, %[4]d AS weight FROM ratings WHERE updated_at >= $%[1]d::timestamp
+		UNION ALL
+		SELECT movie_id, created_at, %[5]d FROM favorites WHERE created_at >= $%[1]d::timestamp
+		UNION ALL
+		SELECT movie_id, created_at, %[6]d FROM comments
+		WHERE created_at >= $%[1]d::timestamp AND is_deleted = false AND is_hidden = false
+	) e
+	GROUP BY e.movie_id`, since, now, decay, trendingRatingWeight, trendingFavoriteWeight, trendingCommentWeight)
+}
+
+// trendingArgs returns the since, now and decay parameters of trendingQuery for the window. Activity loses
+// half of its weight every quarter of the window
+func trendingArgs(window time.Duration) []interface{} {
+	now := time.Now()
+	halfLife := window / 4
+	decay := halfLife.Seconds() / math.Ln2
+
+	return []interface{}{now.Add(-window), now, decay}
+}
+
+// GetTrendingMovies returns up to limit movies with the most activity over the window, recent activity counts
+// more than older one
+func (m *DBModel) GetTrendingMovies(window time.Duration, limit int, cert *CertificationLimit, userID ...int) ([]*Movie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := append(trendingArgs(window), limit)
+
+	query := `SELECT m.id, m.title, m.image, m.description, m.year, m.release_date,
+		` + ratingColumns + `,
+		m.runtime, t.score
+	FROM
+		(` + trendingQuery(1, 2, 3) + `) t
+		JOIN movies m ON (m.id = t.movie_id)` + ratingStatsQuery + `
+	WHERE ` + certificationAllowed("m.id", cert, &args) + `
+	ORDER BY t.score DESC, m.id ASC
+	LIMIT $4`
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+		var image sql.NullString
+		var rating, weighted sql.NullFloat64
+		err = rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&image,
+			&movie.Description,
+			&movie.Year,
+			&movie.ReleaseDate,
+			&rating,
+			&weighted,
+			&movie.VoteCount,
+			&movie.Runtime,
+			&movie.TrendingScore,
+		)
+		if err != nil {
+			return nil, err
+		}
+		setRating(&movie, rating, weighted)
+		movie.Image = movieImage(image)
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(userID) > 0 && userID[0] > 0 {
+		for _, movie := range movies {
+			movie.UserRating, err = m.userRating(ctx, movie.ID, userID[0])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return movies, nil
+}
+
+// PinFeaturedMovie is help to pin a movie on the featured movies at the given position, pinning it again
+// moves it. A position of zero puts it after the other pinned movies
+func (m *DBModel) PinFeaturedMovie(movieID, position, adminID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into featured_movies (movie_id, position, added_by, created_at)
+	select m.id,
+		CASE WHEN $2 > 0 THEN $2 ELSE (SELECT COALESCE(MAX(position), 0) + 1 FROM featured_movies WHERE movie_id <> $1) END,
+		$3, $4
+	from movies m where m.id = $1
+	on conflict (movie_id) do update set position = excluded.position, added_by = excluded.added_by`
+
+	result, err := m.DB.ExecContext(ctx, stmt, movieID, position, adminID, time.Now())
+	if err != nil {
+		return errors.New("failed to pin the movie")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("invalid movie id")
+	}
+
+	return nil
+}
+
+// UnpinFeaturedMovie is help to take a movie off the pinned featured movies
+func (m *DBModel) UnpinFeaturedMovie(movieID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from featured_movies where movie_id = $1`, movieID)
+	if err != nil {
+		return errors.New("failed to unpin the movie")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFeatured
+	}
+
+	return nil
+}
+
+// GetPinnedMovies returns every pinned featured movie in the order they are shown
+func (m *DBModel) GetPinnedMovies() ([]*FeaturedMovie, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `SELECT fm.movie_id, m.title, m.image, fm.position, COALESCE(fm.added_by, 0), fm.created_at
+	FROM
+		featured_movies fm
+		JOIN movies m ON (m.id = fm.movie_id)
+	ORDER BY fm.position ASC, fm.created_at DESC`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	featured := []*FeaturedMovie{}
+	for rows.Next() {
+		var f FeaturedMovie
+		var image sql.NullString
+		err = rows.Scan(&f.MovieID, &f.Title, &image, &f.Position, &f.AddedBy, &f.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		f.Image = movieImage(image)
+
+		featured = append(featured, &f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return featured, nil
+}
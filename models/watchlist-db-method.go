@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotInWatchlist is returned when a movie is removed from a watchlist that does not hold it
+var ErrNotInWatchlist = errors.New("the movie is not in the watchlist")
+
+// AddToWatchlist is help to add a movie to the watchlist of the user, adding it again keeps the first entry
+func (m *DBModel) AddToWatchlist(userID, movieID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int
+	stmt := `insert into watchlist (user_id, movie_id, created_at)
+	select $1, m.id, $3 from movies m where m.id = $2
+	on conflict (user_id, movie_id) do update set user_id = excluded.user_id
+	RETURNING id`
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID, movieID, time.Now()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("invalid movie id")
+	}
+	if err != nil {
+		return 0, errors.New("failed to add the movie to the watchlist")
+	}
+
+	return id, nil
+}
+
+// RemoveFromWatchlist is help to take a movie out of the watchlist of the user
+func (m *DBModel) RemoveFromWatchlist(userID, movieID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `delete from watchlist where user_id = $1 and movie_id = $2`, userID, movieID)
+	if err != nil {
+		return errors.New("failed to remove the movie from the watchlist")
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotInWatchlist
+	}
+
+	return nil
+}
+
+// GetWatchlist returns a page of the watchlist of the user, the latest added first
+func (m *DBModel) GetWatchlist(userID, page, perPage int, cert *CertificationLimit) (*PaginatedWatchlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	offset := (page - 1) * perPage
+
+	args := []interface{}{userID}
+	where := ` WHERE w.user_id = $1 AND ` + certificationAllowed("w.movie_id", cert, &args)
+
+	var totalCount int
+	err := m.DB.QueryRowContext(ctx, `SELECT COUNT(w.id) FROM watchlist w`+where, args...).Scan(&totalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT w.id, w.movie_id, m.title, m.year, m.image, w.created_at
+	FROM
+		watchlist w
+		JOIN movies m ON (m.id = w.movie_id)` + where + `
+	ORDER BY w.created_at DESC, w.id DESC` + fmt.Sprintf(" LIMIT %d OFFSET %d", perPage, offset)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*WatchlistItem{}
+	for rows.Next() {
+		var item WatchlistItem
+		var image sql.NullString
+		err = rows.Scan(
+			&item.ID,
+			&item.MovieID,
+			&item.MovieTitle,
+			&item.Year,
+			&image,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		item.Image = movieImage(image)
+		movies = append(movies, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &PaginatedWatchlist{
+		TotalCount:  totalCount,
+		PerPage:     perPage,
+		CurrentPage: page,
+		Movies:      movies,
+	}, nil
+}
+
+// inWatchlist tells if a movie is in the watchlist of the user
+func (m *DBModel) inWatchlist(ctx context.Context, movieID, userID int) (bool, error) {
+	var exists bool
+	query := `select exists(select 1 from watchlist where movie_id = $1 and user_id = $2)`
+	err := m.DB.QueryRowContext(ctx, query, movieID, userID).Scan(&exists)
+
+	return exists, err
+}
@@ -0,0 +1,150 @@
+package recommend
+
+import (
+	"math"
+	"sort"
+)
+
+// Rating is the score a user gave to a movie
+type Rating struct {
+	UserID  int
+	MovieID int
+	Score   float64
+}
+
+// Neighbor is a movie rated like another one with their similarity between -1 and 1
+type Neighbor struct {
+	ID         int
+	Similarity float64
+}
+
+// pairStats sums what the adjusted cosine of a pair of movies needs over the users who rated both
+type pairStats struct {
+	dot, sqA, sqB float64
+	common        int
+}
+
+// TrainItemItem builds an item-item collaborative filtering model from ratings. Each rating is centered on the
+// mean of its user, so generous and harsh raters count alike (adjusted cosine), and pairs of movies rated by
+// fewer than minCommon users are ignored. It keeps up to neighbors of the most similar movies of every movie,
+// only positive similarities are kept
+func TrainItemItem(ratings []Rating, neighbors, minCommon int) map[int][]Neighbor {
+	// group the ratings by user
+	byUser := make(map[int][]Rating)
+	for _, r := range ratings {
+		byUser[r.UserID] = append(byUser[r.UserID], r)
+	}
+
+	pairs := make(map[[2]int]*pairStats)
+	for _, rated := range byUser {
+		if len(rated) < 2 {
+			continue
+		}
+
+		mean := 0.0
+		for _, r := range rated {
+			mean += r.Score
+		}
+		mean /= float64(len(rated))
+
+		for i := range rated {
+			for j := i + 1; j < len(rated); j++ {
+				a, b := rated[i], rated[j]
+				if a.MovieID > b.MovieID {
+					a, b = b, a
+				}
+
+				key := [2]int{a.MovieID, b.MovieID}
+				stats := pairs[key]
+				if stats == nil {
+					stats = &pairStats{}
+					pairs[key] = stats
+				}
+
+				da, db := a.Score-mean, b.Score-mean
+				stats.dot += da * db
+				stats.sqA += da * da
+				stats.sqB += db * db
+				stats.common++
+			}
+		}
+	}
+
+	model := make(map[int][]Neighbor)
+	for key, stats := range pairs {
+		if stats.common < minCommon || stats.sqA == 0 || stats.sqB == 0 {
+			continue
+		}
+
+		similarity := stats.dot / math.Sqrt(stats.sqA*stats.sqB)
+		if similarity <= 0 {
+			continue
+		}
+
+		model[key[0]] = append(model[key[0]], Neighbor{ID: key[1], Similarity: similarity})
+		model[key[1]] = append(model[key[1]], Neighbor{ID: key[0], Similarity: similarity})
+	}
+
+	for id, list := range model {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Similarity != list[j].Similarity {
+				return list[i].Similarity > list[j].Similarity
+			}
+			return list[i].ID < list[j].ID
+		})
+
+		if len(list) > neighbors {
+			model[id] = list[:neighbors]
+		}
+	}
+
+	return model
+}
+
+// Evidence is a movie the user rated that is a neighbor of a candidate
+type Evidence struct {
+	CandidateID int
+	Similarity  float64
+	Score       float64 // the rating the user gave to the neighbor
+}
+
+// shrinkage damps candidates backed by a single weak neighbor
+const shrinkage = 1.0
+
+// Predict ranks candidates for a user from the movies they rated. A candidate scores the similarity weighted
+// deviation of the user's ratings of its neighbors from the user's mean, candidates the user would likely rate
+// below their mean are left out. It returns up to limit candidates, the best first
+func Predict(evidence []Evidence, mean float64, limit int) []Scored {
+	type sums struct{ weighted, weights float64 }
+
+	byCandidate := make(map[int]*sums)
+	for _, e := range evidence {
+		s := byCandidate[e.CandidateID]
+		if s == nil {
+			s = &sums{}
+			byCandidate[e.CandidateID] = s
+		}
+		s.weighted += e.Similarity * (e.Score - mean)
+		s.weights += math.Abs(e.Similarity)
+	}
+
+	var scored []Scored
+	for id, s := range byCandidate {
+		if score := s.weighted / (s.weights + shrinkage); score > 0 {
+			scored = append(scored, Scored{ID: id, Score: score})
+		}
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].ID < scored[j].ID
+	})
+
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	return scored
+}
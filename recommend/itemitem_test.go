@@ -0,0 +1,150 @@
+package recommend
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTrainItemItem(t *testing.T) {
+	// both users like movies 1 and 2 and dislike movie 3, movie 4 leans to the liked side
+	ratings := []Rating{
+		{UserID: 1, MovieID: 1, Score: 5},
+		{UserID: 1, MovieID: 2, Score: 5},
+		{UserID: 1, MovieID: 3, Score: 1},
+		{UserID: 1, MovieID: 4, Score: 4},
+		{UserID: 2, MovieID: 1, Score: 4},
+		{UserID: 2, MovieID: 2, Score: 4},
+		{UserID: 2, MovieID: 3, Score: 1},
+		{UserID: 2, MovieID: 4, Score: 4},
+		// a single rating says nothing about pairs
+		{UserID: 3, MovieID: 3, Score: 5},
+	}
+
+	tests := []struct {
+		name      string
+		ratings   []Rating
+		neighbors int
+		minCommon int
+		want      map[int][]int
+	}{
+		{
+			name:      "only positive similarities, best first",
+			ratings:   ratings,
+			neighbors: 10,
+			minCommon: 2,
+			want:      map[int][]int{1: {2, 4}, 2: {1, 4}, 4: {1, 2}},
+		},
+		{
+			name:      "cut at the neighbors",
+			ratings:   ratings,
+			neighbors: 1,
+			minCommon: 2,
+			want:      map[int][]int{1: {2}, 2: {1}, 4: {1}},
+		},
+		{
+			name:      "too few common raters",
+			ratings:   ratings,
+			neighbors: 10,
+			minCommon: 3,
+			want:      map[int][]int{},
+		},
+		{
+			name:      "no ratings",
+			neighbors: 10,
+			minCommon: 1,
+			want:      map[int][]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := TrainItemItem(tt.ratings, tt.neighbors, tt.minCommon)
+
+			got := make(map[int][]int, len(model))
+			for id, list := range model {
+				for _, n := range list {
+					got[id] = append(got[id], n.ID)
+					if n.Similarity <= 0 || n.Similarity > 1+1e-9 {
+						t.Errorf("similarity of %d and %d = %v, want it in (0, 1]", id, n.ID, n.Similarity)
+					}
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TrainItemItem() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// movies 1 and 2 are rated the same way by everyone
+	model := TrainItemItem(ratings, 10, 2)
+	if got := model[1][0].Similarity; math.Abs(got-1) > 1e-9 {
+		t.Errorf("similarity of 1 and 2 = %v, want 1", got)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	evidence := []Evidence{
+		{CandidateID: 10, Similarity: 0.8, Score: 5},
+		{CandidateID: 10, Similarity: 0.4, Score: 4},
+		{CandidateID: 20, Similarity: 0.9, Score: 2},
+		{CandidateID: 30, Similarity: 0.5, Score: 5},
+		{CandidateID: 40, Similarity: 0.5, Score: 5},
+	}
+
+	tests := []struct {
+		name       string
+		evidence   []Evidence
+		mean       float64
+		limit      int
+		want       []int
+		wantScores []float64
+	}{
+		{
+			name:       "below the mean is left out, ties by id",
+			evidence:   evidence,
+			mean:       3,
+			limit:      10,
+			want:       []int{10, 30, 40},
+			wantScores: []float64{2.0 / 2.2, 1.0 / 1.5, 1.0 / 1.5},
+		},
+		{
+			name:       "cut at the limit",
+			evidence:   evidence,
+			mean:       3,
+			limit:      1,
+			want:       []int{10},
+			wantScores: []float64{2.0 / 2.2},
+		},
+		{
+			name:     "a generous rater gets nothing from average ratings",
+			evidence: evidence,
+			mean:     5,
+			limit:    10,
+			want:     nil,
+		},
+		{
+			name:  "no evidence",
+			mean:  3,
+			limit: 10,
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scored := Predict(tt.evidence, tt.mean, tt.limit)
+
+			if got := ids(scored); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Predict() = %v, want %v", got, tt.want)
+			}
+
+			for i, want := range tt.wantScores {
+				if math.Abs(scored[i].Score-want) > 1e-9 {
+					t.Errorf("score of %d = %v, want %v", scored[i].ID, scored[i].Score, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package recommend
+
+import (
+	"math"
+	"sort"
+)
+
+// Item is what the scoring knows about a movie
+type Item struct {
+	ID     int
+	Genres []int
+	People []int   // cast and crew, empty when unknown
+	Year   int     // zero when unknown
+	Rating float64 // the weighted rating out of 10, zero for unrated movies
+}
+
+// Weights sets how much each signal counts and a zero weight turns a signal off, a signal that is unknown for
+// either movie is left out and the others are scaled up
+type Weights struct {
+	Genre    float64
+	People   float64
+	Year     float64
+	Rating   float64
+	YearSpan int // movies this many years apart or more get nothing for the year
+}
+
+// DefaultWeights leans on genres and people, year and rating break the ties
+var DefaultWeights = Weights{Genre: 0.5, People: 0.3, Year: 0.1, Rating: 0.1, YearSpan: 20}
+
+// Scored is a related movie with its score between 0 and 1
+type Scored struct {
+	ID    int
+	Score float64
+}
+
+// jaccard returns the share of the ids found in both a and b out of the ids found in either
+func jaccard(a, b []int) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	seen := make(map[int]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+
+	shared, union := 0, len(seen)
+	counted := make(map[int]bool, len(b))
+	for _, id := range b {
+		if counted[id] {
+			continue
+		}
+		counted[id] = true
+		if seen[id] {
+			shared++
+		} else {
+			union++
+		}
+	}
+
+	return float64(shared) / float64(union)
+}
+
+// Score tells how well candidate fits as a movie like target. Movies sharing no genre and nobody score zero,
+// so do movies that only share a signal turned off, a similar year and a good rating alone don't make a
+// movie related
+func Score(target, candidate *Item, w Weights) float64 {
+	genres := jaccard(target.Genres, candidate.Genres)
+	people := jaccard(target.People, candidate.People)
+	if genres*w.Genre == 0 && people*w.People == 0 {
+		return 0
+	}
+
+	sum, total := genres*w.Genre, w.Genre
+
+	if len(target.People) > 0 && len(candidate.People) > 0 {
+		sum += people * w.People
+		total += w.People
+	}
+
+	if target.Year > 0 && candidate.Year > 0 && w.YearSpan > 0 {
+		gap := math.Abs(float64(target.Year - candidate.Year))
+		sum += math.Max(0, 1-gap/float64(w.YearSpan)) * w.Year
+		total += w.Year
+	}
+
+	sum += math.Min(candidate.Rating, 10) / 10 * w.Rating
+	total += w.Rating
+
+	if total == 0 {
+		return 0
+	}
+
+	return sum / total
+}
+
+// Similar returns up to limit candidates most like target, the best first
+func Similar(target *Item, candidates []Item, w Weights, limit int) []Scored {
+	var scored []Scored
+	for i := range candidates {
+		if candidates[i].ID == target.ID {
+			continue
+		}
+
+		if score := Score(target, &candidates[i], w); score > 0 {
+			scored = append(scored, Scored{ID: candidates[i].ID, Score: score})
+		}
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].ID < scored[j].ID
+	})
+
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+
+	return scored
+}
+
+// Related returns up to limit related movies for every item, keyed by the id of the item
+func Related(items []Item, w Weights, limit int) map[int][]Scored {
+	related := make(map[int][]Scored, len(items))
+	for i := range items {
+		related[items[i].ID] = Similar(&items[i], items, w, limit)
+	}
+
+	return related
+}
@@ -0,0 +1,122 @@
+package recommend
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// ids returns the ids of scored movies in their order
+func ids(scored []Scored) []int {
+	var out []int
+	for _, s := range scored {
+		out = append(out, s.ID)
+	}
+	return out
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    Item
+		candidate Item
+		weights   Weights
+		want      float64
+	}{
+		{
+			name:      "no shared genre or person",
+			target:    Item{ID: 1, Genres: []int{1, 2}},
+			candidate: Item{ID: 2, Genres: []int{3}, Rating: 9},
+			weights:   DefaultWeights,
+			want:      0,
+		},
+		{
+			name:      "unknown signals are left out",
+			target:    Item{ID: 1, Genres: []int{1, 2}},
+			candidate: Item{ID: 2, Genres: []int{1, 2}},
+			weights:   DefaultWeights,
+			want:      0.5 / 0.6,
+		},
+		{
+			name:      "every signal",
+			target:    Item{ID: 1, Genres: []int{1, 2}, People: []int{10, 11}, Year: 2000},
+			candidate: Item{ID: 2, Genres: []int{2, 3}, People: []int{10}, Year: 2010, Rating: 8},
+			weights:   DefaultWeights,
+			want:      0.5/3 + 0.3*0.5 + 0.1*0.5 + 0.1*0.8,
+		},
+		{
+			name:      "years further apart than the span",
+			target:    Item{ID: 1, Genres: []int{1}, Year: 2000},
+			candidate: Item{ID: 2, Genres: []int{1}, Year: 1970},
+			weights:   DefaultWeights,
+			want:      0.5 / 0.7,
+		},
+		{
+			name:      "rating is capped at 10",
+			target:    Item{ID: 1, Genres: []int{1}},
+			candidate: Item{ID: 2, Genres: []int{1}, Rating: 12},
+			weights:   Weights{Genre: 0.5, Rating: 0.5},
+			want:      1,
+		},
+		{
+			name:      "zero rating weight",
+			target:    Item{ID: 1, Genres: []int{1, 2}},
+			candidate: Item{ID: 2, Genres: []int{1}, Rating: 10},
+			weights:   Weights{Genre: 1},
+			want:      0.5,
+		},
+		{
+			name:      "zero genre weight and only genres shared",
+			target:    Item{ID: 1, Genres: []int{1}},
+			candidate: Item{ID: 2, Genres: []int{1}, Rating: 9},
+			weights:   Weights{People: 0.3, Year: 0.1, Rating: 0.1, YearSpan: 20},
+			want:      0,
+		},
+		{
+			name:      "zero genre weight and people shared",
+			target:    Item{ID: 1, Genres: []int{1}, People: []int{10}},
+			candidate: Item{ID: 2, Genres: []int{2}, People: []int{10}},
+			weights:   Weights{People: 1},
+			want:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Score(&tt.target, &tt.candidate, tt.weights)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	target := Item{ID: 1, Genres: []int{1}}
+	candidates := []Item{
+		{ID: 1, Genres: []int{1}, Rating: 10},
+		{ID: 2, Genres: []int{1}, Rating: 9},
+		{ID: 3, Genres: []int{1}, Rating: 5},
+		{ID: 4, Genres: []int{2}, Rating: 10},
+		{ID: 5, Genres: []int{1}, Rating: 9},
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []int
+	}{
+		{name: "best first, ties by id", limit: 10, want: []int{2, 5, 3}},
+		{name: "cut at the limit", limit: 2, want: []int{2, 5}},
+		{name: "zero limit", limit: 0, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(Similar(&target, candidates, DefaultWeights, tt.limit))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Similar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package spoiler
+
+import (
+	"strings"
+)
+
+// Marker wraps spoiler text inside comments and reviews, e.g. "the ||butler did it||"
+const Marker = "||"
+
+// RedactedText is shown in place of a hidden spoiler
+const RedactedText = "[spoiler]"
+
+// Segment is a piece of user text, spoiler segments are only shown when revealed
+type Segment struct {
+	Text     string `json:"text"`
+	Spoiler  bool   `json:"spoiler"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// Parse splits text into plain and spoiler segments. A marker without a closing pair is kept as plain text.
+func Parse(text string) []Segment {
+	parts := strings.Split(text, Marker)
+
+	// an odd number of markers leaves the last one unclosed
+	if len(parts)%2 == 0 {
+		last := parts[len(parts)-2] + Marker + parts[len(parts)-1]
+		parts = append(parts[:len(parts)-2], last)
+	}
+
+	segments := []Segment{}
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+
+		isSpoiler := i%2 == 1
+
+		// join neighbours of the same kind left over by empty spoilers like "a|||| b"
+		if n := len(segments); n > 0 && segments[n-1].Spoiler == isSpoiler {
+			segments[n-1].Text += part
+			continue
+		}
+
+		segments = append(segments, Segment{Text: part, Spoiler: isSpoiler})
+	}
+
+	return segments
+}
+
+// Whole marks all of the text as a spoiler
+func Whole(text string) []Segment {
+	return []Segment{{Text: text, Spoiler: true}}
+}
+
+// HasSpoiler reports whether any segment is a spoiler
+func HasSpoiler(segments []Segment) bool {
+	for _, segment := range segments {
+		if segment.Spoiler {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redact returns a copy of the segments with the text of spoilers removed
+func Redact(segments []Segment) []Segment {
+	redacted := make([]Segment, len(segments))
+	for i, segment := range segments {
+		if segment.Spoiler {
+			segment.Text = ""
+			segment.Redacted = true
+		}
+		redacted[i] = segment
+	}
+
+	return redacted
+}
+
+// Text joins the segments back into plain text without markers, redacted spoilers become RedactedText
+func Text(segments []Segment) string {
+	var sb strings.Builder
+	for _, segment := range segments {
+		if segment.Redacted {
+			sb.WriteString(RedactedText)
+			continue
+		}
+		sb.WriteString(segment.Text)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,181 @@
+package spoiler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Segment
+	}{
+		{
+			name: "no spoiler",
+			text: "a fine movie",
+			want: []Segment{{Text: "a fine movie"}},
+		},
+		{
+			name: "spoiler at the end",
+			text: "the ||butler did it||",
+			want: []Segment{{Text: "the "}, {Text: "butler did it", Spoiler: true}},
+		},
+		{
+			name: "spoiler at the start",
+			text: "||he dies|| in the end",
+			want: []Segment{{Text: "he dies", Spoiler: true}, {Text: " in the end"}},
+		},
+		{
+			name: "whole text",
+			text: "||all of it||",
+			want: []Segment{{Text: "all of it", Spoiler: true}},
+		},
+		{
+			name: "two spoilers",
+			text: "||a|| and ||b||",
+			want: []Segment{{Text: "a", Spoiler: true}, {Text: " and "}, {Text: "b", Spoiler: true}},
+		},
+		{
+			name: "unclosed marker is plain text",
+			text: "a ||b",
+			want: []Segment{{Text: "a ||b"}},
+		},
+		{
+			name: "unclosed marker after a spoiler",
+			text: "a ||b|| c ||d",
+			want: []Segment{{Text: "a "}, {Text: "b", Spoiler: true}, {Text: " c ||d"}},
+		},
+		{
+			name: "markers don't nest",
+			text: "||a ||b|| c||",
+			want: []Segment{{Text: "a ", Spoiler: true}, {Text: "b"}, {Text: " c", Spoiler: true}},
+		},
+		{
+			name: "empty spoiler joins its neighbours",
+			text: "a |||| b",
+			want: []Segment{{Text: "a  b"}},
+		},
+		{
+			name: "only an empty spoiler",
+			text: "||||",
+			want: []Segment{},
+		},
+		{
+			name: "a lone pipe is kept",
+			text: "a | b",
+			want: []Segment{{Text: "a | b"}},
+		},
+		{
+			name: "empty text",
+			text: "",
+			want: []Segment{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+		want     []Segment
+	}{
+		{
+			name:     "spoilers lose their text",
+			segments: []Segment{{Text: "the "}, {Text: "butler", Spoiler: true}, {Text: " did it"}},
+			want:     []Segment{{Text: "the "}, {Spoiler: true, Redacted: true}, {Text: " did it"}},
+		},
+		{
+			name:     "no spoiler",
+			segments: []Segment{{Text: "a fine movie"}},
+			want:     []Segment{{Text: "a fine movie"}},
+		},
+		{
+			name:     "no segments",
+			segments: []Segment{},
+			want:     []Segment{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := append([]Segment{}, tt.segments...)
+
+			if got := Redact(tt.segments); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Redact() = %+v, want %+v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(tt.segments, before) {
+				t.Errorf("Redact() changed its input to %+v", tt.segments)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+		want     string
+	}{
+		{
+			name:     "revealed spoilers are plain text",
+			segments: []Segment{{Text: "the "}, {Text: "butler", Spoiler: true}},
+			want:     "the butler",
+		},
+		{
+			name:     "redacted spoilers",
+			segments: []Segment{{Spoiler: true, Redacted: true}, {Text: " and "}, {Spoiler: true, Redacted: true}},
+			want:     RedactedText + " and " + RedactedText,
+		},
+		{
+			name: "no segments",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.segments); got != tt.want {
+				t.Errorf("Text() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		text         string
+		wantText     string
+		wantRedacted string
+	}{
+		{text: "a fine movie", wantText: "a fine movie", wantRedacted: "a fine movie"},
+		{text: "the ||butler did it||", wantText: "the butler did it", wantRedacted: "the " + RedactedText},
+		{text: "||he dies|| in the end", wantText: "he dies in the end", wantRedacted: RedactedText + " in the end"},
+		{text: "a ||b|| c ||d", wantText: "a b c ||d", wantRedacted: "a " + RedactedText + " c ||d"},
+		{text: "a |||| b", wantText: "a  b", wantRedacted: "a  b"},
+		{text: "", wantText: "", wantRedacted: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			segments := Parse(tt.text)
+
+			if got := Text(segments); got != tt.wantText {
+				t.Errorf("Text(Parse(%q)) = %q, want %q", tt.text, got, tt.wantText)
+			}
+
+			if got := Text(Redact(segments)); got != tt.wantRedacted {
+				t.Errorf("Text(Redact(Parse(%q))) = %q, want %q", tt.text, got, tt.wantRedacted)
+			}
+		})
+	}
+}